@@ -0,0 +1,170 @@
+package ripo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// AccessLogFormat: output format of AccessLogger
+type AccessLogFormat int
+
+const (
+	AccessLogCommon   AccessLogFormat = iota // Common Log Format
+	AccessLogCombined                        // Combined Log Format, CLF with referer and user agent
+	AccessLogJSON                            // JSON lines, with all fields of AccessLogEntry
+)
+
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLogEntry: a line of access log
+type AccessLogEntry struct {
+	Time      time.Time     `json:"time"`
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	Route     string        `json:"route,omitempty"` // pattern of Router route
+	Handler   string        `json:"handler"`
+	Status    int           `json:"status"`
+	Code      string        `json:"code,omitempty"` // error code, empty if there was no error
+	Bytes     int64         `json:"bytes"`
+	Latency   time.Duration `json:"-"`
+	RemoteIP  string        `json:"remoteIp"`
+	RequestID string        `json:"requestId,omitempty"`
+	UserAgent string        `json:"userAgent,omitempty"`
+	Referer   string        `json:"referer,omitempty"`
+
+	requestURI string
+	proto      string
+}
+
+// IsError: true if response status is 4xx or 5xx, or handler returned an error
+func (entry *AccessLogEntry) IsError() bool {
+	return entry.Status >= 400 || entry.Code != ""
+}
+
+// AccessLogger: writes a line for each request to writer
+// errors are always logged, but successful requests can be sampled with SampleRate
+type AccessLogger struct {
+	writer     io.Writer
+	format     AccessLogFormat
+	sampleRate float64
+
+	mutex sync.Mutex
+}
+
+// NewAccessLogger: creates access logger that logs all requests to writer in given format
+func NewAccessLogger(writer io.Writer, format AccessLogFormat) *AccessLogger {
+	if writer == nil {
+		panic("NewAccessLogger: nil writer")
+	}
+	switch format {
+	case AccessLogCommon, AccessLogCombined, AccessLogJSON:
+	default:
+		panic(fmt.Sprintf("NewAccessLogger: invalid format %d", format))
+	}
+	return &AccessLogger{
+		writer:     writer,
+		format:     format,
+		sampleRate: 1,
+	}
+}
+
+// SampleRate: set fraction of successful requests that are logged, between 0 and 1
+func (al *AccessLogger) SampleRate(rate float64) *AccessLogger {
+	if rate < 0 || rate > 1 {
+		panic(fmt.Sprintf("AccessLogger.SampleRate: rate %v is not between 0 and 1", rate))
+	}
+	al.sampleRate = rate
+	return al
+}
+
+// Log: writes entry, unless it's a successful request that is not sampled
+func (al *AccessLogger) Log(entry *AccessLogEntry) {
+	if !entry.IsError() && al.sampleRate < 1 && rand.Float64() >= al.sampleRate {
+		return
+	}
+	line := al.formatEntry(entry)
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+	_, err := al.writer.Write(line)
+	if err != nil {
+		getLogger().Warn("error in writing access log", slog.String("error", err.Error()))
+	}
+}
+
+func (al *AccessLogger) formatEntry(entry *AccessLogEntry) []byte {
+	if al.format == AccessLogJSON {
+		line, _ := json.Marshal(&accessLogJSONEntry{
+			AccessLogEntry: entry,
+			LatencyMs:      float64(entry.Latency.Microseconds()) / 1000,
+		})
+		return append(line, '\n')
+	}
+	size := "-"
+	if entry.Bytes > 0 {
+		size = fmt.Sprint(entry.Bytes)
+	}
+	line := fmt.Sprintf(
+		"%s - - [%s] \"%s %s %s\" %d %s",
+		clfValue(entry.RemoteIP),
+		entry.Time.Format(clfTimeFormat),
+		entry.Method,
+		entry.requestURI,
+		entry.proto,
+		entry.Status,
+		size,
+	)
+	if al.format == AccessLogCombined {
+		line += fmt.Sprintf(" %q %q", clfValue(entry.Referer), clfValue(entry.UserAgent))
+	}
+	return []byte(line + "\n")
+}
+
+type accessLogJSONEntry struct {
+	*AccessLogEntry
+	LatencyMs float64 `json:"latencyMs"`
+}
+
+func clfValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// accessLogger: global access logger, nil means disabled
+var accessLogger *AccessLogger
+
+// SetAccessLogger: set global access logger, nil disables access log
+// Can be overridden per handler with AccessLog and DisableAccessLog options
+func SetAccessLogger(al *AccessLogger) {
+	accessLogger = al
+}
+
+// AccessLog: set access logger of this handler, overriding global SetAccessLogger
+func AccessLog(al *AccessLogger) HandlerOption {
+	return func(config *handlerConfig) {
+		config.accessLogger = al
+	}
+}
+
+// DisableAccessLog: never write access log for this handler
+func DisableAccessLog() HandlerOption {
+	return func(config *handlerConfig) {
+		config.disableAccessLog = true
+	}
+}
+
+func (config *handlerConfig) getAccessLogger() *AccessLogger {
+	if config.disableAccessLog {
+		return nil
+	}
+	if config.accessLogger == nil {
+		return accessLogger
+	}
+	return config.accessLogger
+}
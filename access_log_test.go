@@ -0,0 +1,141 @@
+package ripo
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilius/is/v2"
+)
+
+func newAccessLogTestHandler(options ...HandlerOption) http.HandlerFunc {
+	return TranslateHandler(func(req Request) (*Response, error) {
+		if req.Header("X-Fail") != "" {
+			return nil, NewError(PermissionDenied, "", nil)
+		}
+		return &Response{Data: "hello"}, nil
+	}, options...)
+}
+
+func TestAccessLog_Common(t *testing.T) {
+	is := is.New(t)
+	buf := &bytes.Buffer{}
+	handlerFunc := newAccessLogTestHandler(AccessLog(NewAccessLogger(buf, AccessLogCommon)))
+	r := httptest.NewRequest("GET", "/hello?name=x", nil)
+	handlerFunc(httptest.NewRecorder(), r)
+	is.True(regexp.MustCompile(
+		`^192\.0\.2\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /hello\?name=x HTTP/1\.1" 200 5\n$`,
+	).MatchString(buf.String()))
+}
+
+func TestAccessLog_Combined(t *testing.T) {
+	is := is.New(t)
+	buf := &bytes.Buffer{}
+	handlerFunc := newAccessLogTestHandler(AccessLog(NewAccessLogger(buf, AccessLogCombined)))
+	r := httptest.NewRequest("GET", "/hello", nil)
+	r.Header.Set("User-Agent", "test/1.0")
+	r.Header.Set("X-Fail", "1")
+	handlerFunc(httptest.NewRecorder(), r)
+	line := buf.String()
+	is.True(strings.Contains(line, `"GET /hello HTTP/1.1" 403 `))
+	is.True(strings.HasSuffix(line, ` "-" "test/1.0"`+"\n"))
+}
+
+func TestAccessLog_JSON(t *testing.T) {
+	is := is.New(t)
+	SetRequestIDGenerator(func() string { return "rid" })
+	defer SetRequestIDGenerator(nil)
+	buf := &bytes.Buffer{}
+	router := NewRouter(AccessLog(NewAccessLogger(buf, AccessLogJSON)))
+	router.Handle("GET", "/users/{id}", func(req Request) (*Response, error) {
+		return nil, NewError(NotFound, "user not found", nil)
+	})
+	doTestRequest(router, "GET", "/users/12", nil, "")
+	doTestRequest(router, "GET", "/missing", nil, "")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	is.Equal(2, len(lines))
+	{
+		entry := map[string]any{}
+		is.NotErr(json.Unmarshal([]byte(lines[0]), &entry))
+		is.Equal("GET", entry["method"])
+		is.Equal("/users/12", entry["path"])
+		is.Equal("/users/{id}", entry["route"])
+		is.Equal(float64(http.StatusNotFound), entry["status"])
+		is.Equal("NotFound", entry["code"])
+		is.Equal("rid", entry["requestId"])
+		is.True(entry["bytes"].(float64) > 0)
+		_, hasLatency := entry["latencyMs"]
+		is.True(hasLatency)
+		is.True(strings.HasSuffix(entry["handler"].(string), "TestAccessLog_JSON.func2"))
+	}
+	{
+		entry := map[string]any{}
+		is.NotErr(json.Unmarshal([]byte(lines[1]), &entry))
+		is.Equal("/missing", entry["path"])
+		is.Equal("Router", entry["handler"])
+		is.Equal("NotFound", entry["code"])
+		_, hasRoute := entry["route"]
+		is.False(hasRoute)
+	}
+}
+
+func TestAccessLog_Sample(t *testing.T) {
+	is := is.New(t)
+	buf := &bytes.Buffer{}
+	handlerFunc := newAccessLogTestHandler(AccessLog(NewAccessLogger(buf, AccessLogCommon).SampleRate(0)))
+	handlerFunc(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	is.Equal("", buf.String())
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Fail", "1")
+	handlerFunc(httptest.NewRecorder(), r)
+	is.Equal(1, strings.Count(buf.String(), "\n"))
+	is.True(strings.Contains(buf.String(), `" 403 `))
+}
+
+func TestAccessLog_Global(t *testing.T) {
+	is := is.New(t)
+	buf := &bytes.Buffer{}
+	SetAccessLogger(NewAccessLogger(buf, AccessLogCommon))
+	defer SetAccessLogger(nil)
+	newAccessLogTestHandler()(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	is.Equal(1, strings.Count(buf.String(), "\n"))
+	newAccessLogTestHandler(DisableAccessLog())(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	is.Equal(1, strings.Count(buf.String(), "\n"))
+}
+
+func TestAccessLogger_Invalid(t *testing.T) {
+	is := is.New(t)
+	is.ShouldPanic(func() {
+		NewAccessLogger(nil, AccessLogCommon)
+	})
+	is.ShouldPanic(func() {
+		NewAccessLogger(&bytes.Buffer{}, AccessLogFormat(10))
+	})
+	is.ShouldPanic(func() {
+		NewAccessLogger(&bytes.Buffer{}, AccessLogJSON).SampleRate(1.5)
+	})
+}
+
+func TestAccessLogEntry_CLF(t *testing.T) {
+	is := is.New(t)
+	al := NewAccessLogger(&bytes.Buffer{}, AccessLogCombined)
+	entry := &AccessLogEntry{
+		Time:      time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Method:    "POST",
+		Status:    204,
+		RemoteIP:  "10.0.0.1",
+		UserAgent: "curl",
+
+		requestURI: "/items",
+		proto:      "HTTP/1.1",
+	}
+	is.Equal(
+		`10.0.0.1 - - [02/Jan/2020:03:04:05 +0000] "POST /items HTTP/1.1" 204 - "-" "curl"`+"\n",
+		string(al.formatEntry(entry)),
+	)
+}
@@ -0,0 +1,204 @@
+package ripo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const defaultMaxBatchSize = 50
+
+type batchContextKey struct{}
+
+// BatchItem: a sub-request in body of BatchHandler request
+type BatchItem struct {
+	ID     string            `json:"id,omitempty"`
+	Method string            `json:"method,omitempty"` // default is GET
+	Path   string            `json:"path"`             // including query, like "/users/1?fields=name"
+	Header map[string]string `json:"headers,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"` // sent as json
+}
+
+// BatchItemResult: result of a sub-request in BatchHandler response
+type BatchItemResult struct {
+	ID     string            `json:"id,omitempty"`
+	Status int               `json:"status"`
+	Header map[string]string `json:"headers,omitempty"`
+
+	// Body: json value if response is json, or string otherwise
+	Body any `json:"body,omitempty"`
+}
+
+type batchRequestBody struct {
+	Requests []*BatchItem `json:"requests"`
+}
+
+type batchResponseBody struct {
+	Responses []*BatchItemResult `json:"responses"`
+}
+
+// BatchHandler: handles a batch of sub-requests like {"requests": [{"id": "1", "method": "GET", "path": "/users/1"}]}
+// by dispatching them to handler (typically a mux of translated handlers) in-process, and responds with
+// {"responses": [{"id": "1", "status": 200, "headers": {...}, "body": {...}}]} in the same order
+// each sub-request is a separate http.Request, with its own Request, error dispatch and traceback,
+// that inherits headers (except content headers) and context of batch request
+// use BatchConcurrency and MaxBatchSize options to control parallelism and size of batch
+func BatchHandler(handler http.Handler, options ...HandlerOption) http.HandlerFunc {
+	config := newHandlerConfig(options)
+	return TranslateHandler(func(req Request) (*Response, error) {
+		if req.Context().Value(batchContextKey{}) != nil {
+			return nil, NewError(InvalidArgument, "nested batch requests are not allowed", nil)
+		}
+		body := &batchRequestBody{}
+		err := req.BodyTo(body)
+		if err != nil {
+			return nil, err
+		}
+		if len(body.Requests) > config.getMaxBatchSize() {
+			return nil, NewError(
+				InvalidArgument,
+				fmt.Sprintf("too many requests in batch, must be at most %d", config.getMaxBatchSize()),
+				nil,
+			).Add("count", len(body.Requests))
+		}
+		for index, item := range body.Requests {
+			if item == nil || !strings.HasPrefix(item.Path, "/") {
+				return nil, NewError(
+					InvalidArgument,
+					fmt.Sprintf("invalid 'path' in request %d, must start with /", index),
+					nil,
+				)
+			}
+		}
+		parent := req.(*requestImp).r
+		results := make([]*BatchItemResult, len(body.Requests))
+		concurrency := config.getBatchConcurrency()
+		if concurrency == 1 {
+			for index, item := range body.Requests {
+				results[index] = runBatchItem(handler, parent, item, req.Logger())
+			}
+		} else {
+			sem := make(chan struct{}, concurrency)
+			var wg sync.WaitGroup
+			for index, item := range body.Requests {
+				wg.Add(1)
+				sem <- struct{}{}
+				go func(index int, item *BatchItem) {
+					defer wg.Done()
+					defer func() { <-sem }()
+					results[index] = runBatchItem(handler, parent, item, req.Logger())
+				}(index, item)
+			}
+			wg.Wait()
+		}
+		return &Response{
+			Data: &batchResponseBody{Responses: results},
+		}, nil
+	}, options...)
+}
+
+func runBatchItem(handler http.Handler, parent *http.Request, item *BatchItem, logger *slog.Logger) (result *BatchItemResult) {
+	result = &BatchItemResult{ID: item.ID}
+	defer func() {
+		panicMsg := recover()
+		if panicMsg != nil {
+			logger.Error(
+				"panic in batch sub-request",
+				slog.String("subMethod", item.Method),
+				slog.String("subPath", item.Path),
+				slog.Any("panic", panicMsg),
+			)
+			result.Status = http.StatusInternalServerError
+			result.Header = nil
+			result.Body = nil
+		}
+	}()
+	method := item.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	ctx := context.WithValue(parent.Context(), batchContextKey{}, true)
+	var body []byte
+	if len(item.Body) > 0 && !bytes.Equal(item.Body, []byte("null")) {
+		body = item.Body
+	}
+	r, err := http.NewRequestWithContext(ctx, method, item.Path, bytes.NewReader(body))
+	if err != nil {
+		result.Status = http.StatusBadRequest
+		result.Body = newErrorBody(NewError(InvalidArgument, "invalid sub-request", err), RequestIDFromContext(ctx))
+		return
+	}
+	for key, values := range parent.Header {
+		switch http.CanonicalHeaderKey(key) {
+		case "Content-Type", "Content-Length", "Content-Encoding", "Accept-Encoding", "If-None-Match":
+			continue
+		}
+		r.Header[key] = values
+	}
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range item.Header {
+		r.Header.Set(key, value)
+	}
+	r.RemoteAddr = parent.RemoteAddr
+	r.Host = parent.Host
+	w := &batchResponseWriter{header: http.Header{}}
+	handler.ServeHTTP(w, r)
+	result.Status = w.status
+	if result.Status == 0 {
+		result.Status = http.StatusOK
+	}
+	if len(w.header) > 0 {
+		result.Header = make(map[string]string, len(w.header))
+		for key := range w.header {
+			result.Header[key] = w.header.Get(key)
+		}
+	}
+	if w.body.Len() > 0 {
+		resBody := w.body.Bytes()
+		if isJSONMediaType(w.header.Get("Content-Type")) && json.Valid(resBody) {
+			result.Body = json.RawMessage(resBody)
+		} else {
+			result.Body = string(resBody)
+		}
+	}
+	return
+}
+
+func isJSONMediaType(contentType string) bool {
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// batchResponseWriter: records response of a sub-request
+type batchResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *batchResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *batchResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *batchResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(data)
+}
+
+// Flush: no-op, to support streaming handlers
+func (w *batchResponseWriter) Flush() {}
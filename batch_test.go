@@ -0,0 +1,118 @@
+package ripo
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ilius/is/v2"
+)
+
+func newBatchTestMux(options ...HandlerOption) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", TranslateHandler(func(req Request) (*Response, error) {
+		name, err := req.GetString("name", FromBody, FromForm)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{Data: map[string]string{
+			"greeting": "Hello " + *name,
+			"lang":     req.Header("Accept-Language"),
+		}}, nil
+	}))
+	mux.HandleFunc("/text", TranslateHandler(func(req Request) (*Response, error) {
+		return &Response{Data: "plain text"}, nil
+	}))
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("not translated")
+	})
+	mux.Handle("/batch", BatchHandler(mux, options...))
+	return mux
+}
+
+var batchTestHeader = http.Header{
+	"Content-Type":    {"application/json"},
+	"Accept-Language": {"en"},
+}
+
+func TestBatch(t *testing.T) {
+	is := is.New(t)
+	var dispatched []RPCError
+	SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {
+		dispatched = append(dispatched, rpcErr)
+	})
+	defer SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {})
+	w := doTestRequest(newBatchTestMux(), "POST", "/batch", batchTestHeader, `{"requests": [
+		{"id": "a", "path": "/hello?name=John"},
+		{"id": "b", "method": "POST", "path": "/hello", "body": {"name": "Jane"}, "headers": {"Accept-Language": "fr"}},
+		{"id": "c", "method": "POST", "path": "/hello", "body": {}},
+		{"id": "d", "path": "/text"},
+		{"id": "e", "path": "/panic"}
+	]}`)
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal(
+		`{"responses":[`+
+			`{"id":"a","status":200,"headers":{"Content-Type":"application/json; charset=UTF-8","Vary":"Accept"},"body":{"greeting":"Hello John","lang":"en"}},`+
+			`{"id":"b","status":200,"headers":{"Content-Type":"application/json; charset=UTF-8","Vary":"Accept"},"body":{"greeting":"Hello Jane","lang":"fr"}},`+
+			`{"id":"c","status":400,"headers":{"Content-Type":"application/json; charset=UTF-8","Vary":"Accept","X-Content-Type-Options":"nosniff"},`+
+			`"body":{"code":"MissingArgument","error":"missing 'name'"}},`+
+			`{"id":"d","status":200,"headers":{"Content-Type":"text/plain; charset=utf-8"},"body":"plain text"},`+
+			`{"id":"e","status":500}`+
+			`]}`,
+		w.Body.String(),
+	)
+	is.Equal(1, len(dispatched))
+	is.Equal(MissingArgument, dispatched[0].Code())
+}
+
+func TestBatch_Invalid(t *testing.T) {
+	is := is.New(t)
+	mux := newBatchTestMux(MaxBatchSize(2))
+	for body, expected := range map[string]string{
+		`{"requests": [{"path": "/text"}, {"path": "/text"}, {"path": "/text"}]}`: `{"code":"InvalidArgument","error":"too many requests in batch, must be at most 2"}`,
+		`{"requests": [{"path": "text"}]}`:                                        `{"code":"InvalidArgument","error":"invalid 'path' in request 0, must start with /"}`,
+		`{"requests": 1}`:                                                         `{"code":"InvalidArgument","error":"request body is not a valid json"}`,
+	} {
+		w := doTestRequest(mux, "POST", "/batch", batchTestHeader, body)
+		is.Msg("body=%v", body).Equal(http.StatusBadRequest, w.Code)
+		is.Msg("body=%v", body).Equal(expected, w.Body.String())
+	}
+	w := doTestRequest(mux, "POST", "/batch", batchTestHeader, `{"requests": [{"method": "POST", "path": "/batch", "body": {"requests": []}}]}`)
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal(
+		`{"responses":[{"status":400,"headers":{"Content-Type":"application/json; charset=UTF-8","Vary":"Accept","X-Content-Type-Options":"nosniff"},`+
+			`"body":{"code":"InvalidArgument","error":"nested batch requests are not allowed"}}]}`,
+		w.Body.String(),
+	)
+}
+
+func TestBatch_Concurrency(t *testing.T) {
+	is := is.New(t)
+	var running, maxRunning int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", TranslateHandler(func(req Request) (*Response, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return &Response{Data: req.URL().Query().Get("i")}, nil
+	}))
+	handler := BatchHandler(mux, BatchConcurrency(2))
+	w := doTestRequest(handler, "POST", "/batch", batchTestHeader, `{"requests": [
+		{"path": "/slow?i=1"}, {"path": "/slow?i=2"}, {"path": "/slow?i=3"}, {"path": "/slow?i=4"}, {"path": "/slow?i=5"}
+	]}`)
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal(int32(2), atomic.LoadInt32(&maxRunning))
+	body := w.Body.String()
+	for _, i := range []string{"1", "2", "3", "4", "5"} {
+		is.True(strings.Contains(body, `"body":"`+i+`"`))
+	}
+	is.True(strings.Index(body, `"body":"1"`) < strings.Index(body, `"body":"5"`))
+}
@@ -0,0 +1,322 @@
+// Package client: http client for calling ripo services, that decodes error responses back into ripo.RPCError
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ilius/ripo"
+)
+
+// RetryPolicy: retries failed calls with exponential backoff and jitter
+type RetryPolicy struct {
+	MaxAttempts    int // including the first attempt
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// Codes: error codes that are retried, transport errors are reported as Unavailable
+	// and are only retried for idempotent methods, as the request may have been processed
+	Codes []ripo.Code
+}
+
+// DefaultRetryPolicy: retries codes that mean the request was not processed, up to 3 attempts
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+	Multiplier:     2,
+	Codes: []ripo.Code{
+		ripo.Unavailable,
+		ripo.ResourceExhausted,
+		ripo.TooManyRequests,
+		ripo.Aborted,
+		ripo.ResourceLocked,
+	},
+}
+
+func (p *RetryPolicy) retryable(code ripo.Code) bool {
+	for _, retryCode := range p.Codes {
+		if code == retryCode {
+			return true
+		}
+	}
+	return false
+}
+
+// idempotentMethods: methods that can be retried after a transport error
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// backoff: returns random duration in [b/2, b] where b is the exponential backoff for given attempt
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		backoff *= p.Multiplier
+		if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	return time.Duration(backoff/2 + rand.Float64()*backoff/2)
+}
+
+// Client: calls ripo services with json request and response bodies
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client // http.DefaultClient is used if nil
+	Header     http.Header  // added to all requests
+
+	// Retry: nil means no retries
+	Retry *RetryPolicy
+}
+
+// New: creates a client with given base url, like "http://localhost:8080/api"
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Header:  http.Header{},
+	}
+}
+
+type errorBody struct {
+	Code    string         `json:"code"`
+	Error   string         `json:"error"`
+	Details map[string]any `json:"details"`
+
+	RequestID string `json:"requestId"`
+}
+
+// Get: calls GET method on path and decodes response into out (if not nil)
+func (c *Client) Get(ctx context.Context, path string, out any) error {
+	return c.Call(ctx, http.MethodGet, path, nil, out)
+}
+
+// Post: calls POST method on path with in encoded as json, and decodes response into out (if not nil)
+func (c *Client) Post(ctx context.Context, path string, in any, out any) error {
+	return c.Call(ctx, http.MethodPost, path, in, out)
+}
+
+// Put: calls PUT method on path with in encoded as json, and decodes response into out (if not nil)
+func (c *Client) Put(ctx context.Context, path string, in any, out any) error {
+	return c.Call(ctx, http.MethodPut, path, in, out)
+}
+
+// Patch: calls PATCH method on path with in encoded as json, and decodes response into out (if not nil)
+func (c *Client) Patch(ctx context.Context, path string, in any, out any) error {
+	return c.Call(ctx, http.MethodPatch, path, in, out)
+}
+
+// Delete: calls DELETE method on path and decodes response into out (if not nil)
+func (c *Client) Delete(ctx context.Context, path string, out any) error {
+	return c.Call(ctx, http.MethodDelete, path, nil, out)
+}
+
+// Call: calls method on path with in encoded as json (no body if nil), and decodes
+// json response into out (if not nil), retrying based on c.Retry
+// no retry is done if Retry-After of error response is longer than c.Retry.MaxBackoff
+// returned error is always a ripo.RPCError, with code and message (and public details) decoded
+// from error response, or based on http status if response is not a ripo error
+// request ID and trace context of ctx (as set by ripo handlers) are propagated in request headers
+func (c *Client) Call(ctx context.Context, method string, path string, in any, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return ripo.NewError(ripo.InvalidArgument, "", err).Add("method", method).Add("path", path)
+		}
+	}
+	attempt := 1
+	for {
+		retryAfter, isTransportErr, err := c.call(ctx, method, path, body, out)
+		if err == nil {
+			return nil
+		}
+		rpcErr := err.(ripo.RPCError)
+		if c.Retry == nil || attempt >= c.Retry.MaxAttempts || !c.Retry.retryable(rpcErr.Code()) {
+			return rpcErr.Add("attempts", attempt)
+		}
+		if isTransportErr && !idempotentMethods[method] {
+			return rpcErr.Add("attempts", attempt)
+		}
+		if c.Retry.MaxBackoff > 0 && retryAfter > c.Retry.MaxBackoff {
+			return rpcErr.Add("attempts", attempt).Add("retryAfter", retryAfter.String())
+		}
+		wait := c.Retry.backoff(attempt)
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return rpcErr.Add("attempts", attempt)
+		case <-timer.C:
+		}
+		attempt++
+	}
+}
+
+// call: does one attempt, returns Retry-After of response if given,
+// and whether the error is a transport error (no error response was received)
+func (c *Client) call(ctx context.Context, method string, path string, body []byte, out any) (time.Duration, bool, error) {
+	url := c.BaseURL + path
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	r, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+	if err != nil {
+		return 0, false, ripo.NewError(ripo.InvalidArgument, "", err).Add("url", url)
+	}
+	for key, values := range c.Header {
+		r.Header[key] = values
+	}
+	r.Header.Set("Accept", "application/json")
+	if requestID := ripo.RequestIDFromContext(ctx); requestID != "" && r.Header.Get(ripo.RequestIDHeader) == "" {
+		r.Header.Set(ripo.RequestIDHeader, requestID)
+	}
+	if r.Header.Get(ripo.TraceparentHeader) == "" {
+		ripo.InjectTraceContext(ctx, r.Header)
+	}
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(r)
+	if err != nil {
+		return 0, true, transportError(ctx, err).Add("method", method).Add("url", url)
+	}
+	defer res.Body.Close()
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, true, transportError(ctx, err).Add("method", method).Add("url", url)
+	}
+	if res.StatusCode >= 400 {
+		rpcErr := decodeError(res.StatusCode, resBody).Add("method", method).Add("url", url)
+		return parseRetryAfter(res.Header.Get("Retry-After")), false, rpcErr
+	}
+	if out == nil || len(resBody) == 0 || res.StatusCode == http.StatusNoContent {
+		return 0, false, nil
+	}
+	err = json.Unmarshal(resBody, out)
+	if err != nil {
+		return 0, false, ripo.NewError(
+			ripo.Internal, "response body is not a valid json", err,
+		).Add("method", method).Add("url", url).Add("status", res.StatusCode)
+	}
+	return 0, false, nil
+}
+
+func transportError(ctx context.Context, err error) ripo.RPCError {
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return ripo.NewError(ripo.Canceled, "", err)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return ripo.NewError(ripo.DeadlineExceeded, "", err)
+	}
+	return ripo.NewError(ripo.Unavailable, "", err)
+}
+
+// decodeError: decodes error response of a ripo service into RPCError
+func decodeError(status int, body []byte) ripo.RPCError {
+	eb := &errorBody{}
+	err := json.Unmarshal(body, eb)
+	code, ok := ripo.ErrorCodeByName[eb.Code]
+	if err != nil || !ok {
+		return ripo.NewError(
+			codeFromHTTPStatus(status),
+			"",
+			fmt.Errorf("unexpected error response with status %d", status),
+		).Add("status", status).Add("body", string(body))
+	}
+	message := eb.Error
+	if message == code.String() {
+		// message was not set by server
+		message = ""
+	}
+	rpcErr := ripo.NewError(code, message, nil).Add("status", status)
+	for key, value := range eb.Details {
+		rpcErr.AddPublic(key, value)
+	}
+	if eb.RequestID != "" {
+		rpcErr.Add("requestId", eb.RequestID)
+	}
+	return rpcErr
+}
+
+func codeFromHTTPStatus(status int) ripo.Code {
+	switch status {
+	case http.StatusBadRequest:
+		return ripo.InvalidArgument
+	case http.StatusUnauthorized:
+		return ripo.Unauthenticated
+	case http.StatusForbidden:
+		return ripo.PermissionDenied
+	case http.StatusNotFound:
+		return ripo.NotFound
+	case http.StatusRequestTimeout:
+		return ripo.DeadlineExceeded
+	case http.StatusConflict:
+		return ripo.Aborted
+	case http.StatusPreconditionFailed:
+		return ripo.FailedPrecondition
+	case http.StatusRequestEntityTooLarge:
+		return ripo.PayloadTooLarge
+	case http.StatusUnsupportedMediaType:
+		return ripo.UnsupportedMediaType
+	case http.StatusNotAcceptable:
+		return ripo.NotAcceptable
+	case http.StatusTooManyRequests:
+		return ripo.TooManyRequests
+	case http.StatusNotImplemented:
+		return ripo.Unimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return ripo.Unavailable
+	case http.StatusGatewayTimeout:
+		return ripo.DeadlineExceeded
+	}
+	if status >= 500 {
+		return ripo.Internal
+	}
+	return ripo.Unknown
+}
+
+// parseRetryAfter: parses Retry-After header in seconds or http date, returns 0 if invalid
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(value)
+	if err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0
+	}
+	return time.Until(date)
+}
@@ -0,0 +1,252 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ilius/is/v2"
+	"github.com/ilius/ripo"
+)
+
+type greeting struct {
+	Greeting string `json:"greeting"`
+}
+
+func newTestServer(failures *int32) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", ripo.TranslateHandler(func(req ripo.Request) (*ripo.Response, error) {
+		name, err := req.GetString("name", ripo.FromBody, ripo.FromForm)
+		if err != nil {
+			return nil, err
+		}
+		return &ripo.Response{Data: &greeting{Greeting: "Hello " + *name + ", " + req.Header("X-Caller")}}, nil
+	}))
+	mux.HandleFunc("/user", ripo.TranslateHandler(func(req ripo.Request) (*ripo.Response, error) {
+		return nil, ripo.NewError(ripo.NotFound, "user not found", nil).AddPublic("userId", "123")
+	}))
+	mux.HandleFunc("/flaky", ripo.TranslateHandler(func(req ripo.Request) (*ripo.Response, error) {
+		if atomic.AddInt32(failures, -1) >= 0 {
+			return nil, ripo.NewError(ripo.Unavailable, "", nil)
+		}
+		return ripo.NoContent(), nil
+	}))
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestClient_Success(t *testing.T) {
+	is := is.New(t)
+	var failures int32
+	server := newTestServer(&failures)
+	defer server.Close()
+	c := New(server.URL + "/")
+	c.Header.Set("X-Caller", "test")
+	{
+		out := &greeting{}
+		err := c.Get(context.Background(), "/hello?name=John", out)
+		is.NotErr(err)
+		is.Equal("Hello John, test", out.Greeting)
+	}
+	{
+		out := &greeting{}
+		err := c.Post(context.Background(), "/hello", map[string]string{"name": "Jane"}, out)
+		is.NotErr(err)
+		is.Equal("Hello Jane, test", out.Greeting)
+	}
+}
+
+func TestClient_Error(t *testing.T) {
+	is := is.New(t)
+	var failures int32
+	server := newTestServer(&failures)
+	defer server.Close()
+	c := New(server.URL)
+	{
+		err := c.Get(context.Background(), "/user", nil)
+		ripo.AssertError(t, err, ripo.NotFound, "user not found")
+		rpcErr := err.(ripo.RPCError)
+		is.Equal(map[string]any{"userId": "123"}, rpcErr.PublicDetails())
+		is.Equal(http.StatusNotFound, rpcErr.Details()["status"])
+	}
+	{
+		err := c.Post(context.Background(), "/hello", map[string]string{}, nil)
+		ripo.AssertError(t, err, ripo.MissingArgument, "missing 'name'")
+	}
+	{
+		err := c.Get(context.Background(), "/plain", nil)
+		ripo.AssertError(t, err, ripo.Unavailable, "Unavailable")
+	}
+	{
+		err := c.Get(context.Background(), "/missing", nil)
+		ripo.AssertError(t, err, ripo.NotFound, "NotFound")
+	}
+}
+
+func TestClient_RequestID(t *testing.T) {
+	is := is.New(t)
+	server := httptest.NewServer(ripo.TranslateHandler(func(req ripo.Request) (*ripo.Response, error) {
+		return nil, ripo.NewError(ripo.NotFound, "", nil).AddPublic("received", req.RequestID())
+	}))
+	defer server.Close()
+	c := New(server.URL)
+	{
+		ctx := ripo.ContextWithRequestID(context.Background(), "req-1")
+		err := c.Get(ctx, "/", nil)
+		rpcErr := err.(ripo.RPCError)
+		is.Equal("req-1", rpcErr.PublicDetails()["received"])
+		is.Equal("req-1", rpcErr.Details()["requestId"])
+	}
+	{
+		err := c.Get(context.Background(), "/", nil)
+		rpcErr := err.(ripo.RPCError)
+		requestID, _ := rpcErr.Details()["requestId"].(string)
+		is.Equal(32, len(requestID))
+		is.Equal(requestID, rpcErr.PublicDetails()["received"])
+	}
+}
+
+func TestClient_TraceContext(t *testing.T) {
+	is := is.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"greeting":"` + r.Header.Get(ripo.TraceparentHeader) + `"}`))
+	}))
+	defer server.Close()
+	c := New(server.URL)
+	ctx := ripo.ContextWithSpanContext(context.Background(), &ripo.SpanContext{
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:  "00f067aa0ba902b7",
+		Sampled: true,
+	})
+	out := &greeting{}
+	is.NotErr(c.Get(ctx, "/", out))
+	is.Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", out.Greeting)
+}
+
+func TestClient_Retry(t *testing.T) {
+	is := is.New(t)
+	failures := int32(2)
+	server := newTestServer(&failures)
+	defer server.Close()
+	c := New(server.URL)
+	{
+		err := c.Post(context.Background(), "/flaky", nil, nil)
+		ripo.AssertError(t, err, ripo.Unavailable, "Unavailable")
+		is.Equal(1, err.(ripo.RPCError).Details()["attempts"])
+	}
+	c.Retry = &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Multiplier:     2,
+		Codes:          []ripo.Code{ripo.Unavailable},
+	}
+	{
+		err := c.Post(context.Background(), "/flaky", nil, nil)
+		is.NotErr(err)
+	}
+	{
+		atomic.StoreInt32(&failures, 5)
+		err := c.Post(context.Background(), "/flaky", nil, nil)
+		ripo.AssertError(t, err, ripo.Unavailable, "Unavailable")
+		is.Equal(3, err.(ripo.RPCError).Details()["attempts"])
+		is.Equal(int32(2), atomic.LoadInt32(&failures))
+	}
+	{
+		// not retryable
+		err := c.Get(context.Background(), "/user", nil)
+		is.Equal(1, err.(ripo.RPCError).Details()["attempts"])
+	}
+}
+
+func TestClient_Transport(t *testing.T) {
+	var failures int32
+	server := newTestServer(&failures)
+	server.Close()
+	c := New(server.URL)
+	err := c.Get(context.Background(), "/hello", nil)
+	ripo.AssertError(t, err, ripo.Unavailable, "Unavailable")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = c.Get(ctx, "/hello", nil)
+	ripo.AssertError(t, err, ripo.Canceled, "Canceled")
+}
+
+func TestClient_RetryTransport(t *testing.T) {
+	is := is.New(t)
+	var failures int32
+	server := newTestServer(&failures)
+	server.Close()
+	c := New(server.URL)
+	c.Retry = &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Multiplier:     2,
+		Codes:          []ripo.Code{ripo.Unavailable},
+	}
+	{
+		err := c.Get(context.Background(), "/hello", nil)
+		ripo.AssertError(t, err, ripo.Unavailable, "Unavailable")
+		is.Equal(3, err.(ripo.RPCError).Details()["attempts"])
+	}
+	{
+		// request may have been processed, not retried for non-idempotent methods
+		err := c.Post(context.Background(), "/hello", nil, nil)
+		ripo.AssertError(t, err, ripo.Unavailable, "Unavailable")
+		is.Equal(1, err.(ripo.RPCError).Details()["attempts"])
+		err = c.Patch(context.Background(), "/hello", nil, nil)
+		is.Equal(1, err.(ripo.RPCError).Details()["attempts"])
+	}
+}
+
+func TestClient_RetryAfter(t *testing.T) {
+	is := is.New(t)
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "10")
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	c := New(server.URL)
+	c.Retry = &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Multiplier:     2,
+		Codes:          []ripo.Code{ripo.TooManyRequests},
+	}
+	start := time.Now()
+	err := c.Get(context.Background(), "/", nil)
+	// Retry-After is longer than MaxBackoff, gives up without waiting
+	is.True(time.Since(start) < time.Second)
+	ripo.AssertError(t, err, ripo.TooManyRequests, "TooManyRequests")
+	is.Equal(1, err.(ripo.RPCError).Details()["attempts"])
+	is.Equal("10s", err.(ripo.RPCError).Details()["retryAfter"])
+	is.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	is := is.New(t)
+	p := &RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     300 * time.Millisecond,
+		Multiplier:     2,
+	}
+	for i := 0; i < 10; i++ {
+		b := p.backoff(1)
+		is.True(b >= 50*time.Millisecond && b <= 100*time.Millisecond)
+		b = p.backoff(5)
+		is.True(b >= 150*time.Millisecond && b <= 300*time.Millisecond)
+	}
+	is.Equal(3*time.Second, parseRetryAfter("3"))
+	is.Equal(time.Duration(0), parseRetryAfter("x"))
+}
@@ -27,11 +27,15 @@ func _() {
 	_ = x[DataLoss-15]
 	_ = x[MissingArgument-17]
 	_ = x[ResourceLocked-18]
+	_ = x[NotAcceptable-19]
+	_ = x[UnsupportedMediaType-20]
+	_ = x[PayloadTooLarge-21]
+	_ = x[TooManyRequests-22]
 }
 
-const _Code_name = "OKCanceledUnknownInvalidArgumentDeadlineExceededNotFoundAlreadyExistsPermissionDeniedResourceExhaustedFailedPreconditionAbortedOutOfRangeUnimplementedInternalUnavailableDataLossUnauthenticatedMissingArgumentResourceLocked"
+const _Code_name = "OKCanceledUnknownInvalidArgumentDeadlineExceededNotFoundAlreadyExistsPermissionDeniedResourceExhaustedFailedPreconditionAbortedOutOfRangeUnimplementedInternalUnavailableDataLossUnauthenticatedMissingArgumentResourceLockedNotAcceptableUnsupportedMediaTypePayloadTooLargeTooManyRequests"
 
-var _Code_index = [...]uint8{0, 2, 10, 17, 32, 48, 56, 69, 85, 102, 120, 127, 137, 150, 158, 169, 177, 192, 207, 221}
+var _Code_index = [...]uint16{0, 2, 10, 17, 32, 48, 56, 69, 85, 102, 120, 127, 137, 150, 158, 169, 177, 192, 207, 221, 234, 254, 269, 284}
 
 func (i Code) String() string {
 	if i >= Code(len(_Code_index)-1) {
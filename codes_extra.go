@@ -10,4 +10,20 @@ const (
 	// ResourceLocked means that the give resource is currently busy or temporarily locked
 	// by abother request (either by the same user or another user)
 	ResourceLocked Code = 18
+
+	// NotAcceptable means that none of the media types accepted by client
+	// (in Accept header) can be produced for the response
+	NotAcceptable Code = 19
+
+	// UnsupportedMediaType means that the request body is in a format (Content-Type)
+	// that is not supported by server
+	UnsupportedMediaType Code = 20
+
+	// PayloadTooLarge means that the request body is larger than the limit
+	// configured on server, it's a special case of ResourceExhausted
+	PayloadTooLarge Code = 21
+
+	// TooManyRequests means that client has sent too many requests in a given amount of time
+	// (rate limit is exceeded), it's a special case of ResourceExhausted
+	TooManyRequests Code = 22
 )
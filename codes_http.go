@@ -29,7 +29,7 @@
 package ripo
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 )
 
@@ -75,8 +75,16 @@ func HTTPStatusFromCode(code Code) int {
 		return http.StatusBadRequest
 	case ResourceLocked: // added by Saeed Rasooli
 		return http.StatusConflict
+	case NotAcceptable:
+		return http.StatusNotAcceptable
+	case UnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case PayloadTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case TooManyRequests:
+		return http.StatusTooManyRequests
 	}
 
-	log.Printf("Unknown error code: %v", code)
+	getLogger().Warn("unknown error code", slog.Int("code", int(code)))
 	return http.StatusInternalServerError
 }
@@ -21,3 +21,11 @@ func TestHTTPStatusFromCodeOK(t *testing.T) {
 	is := is.New(t)
 	is.Equal(http.StatusOK, HTTPStatusFromCode(OK))
 }
+
+func TestTooManyRequests(t *testing.T) {
+	is := is.New(t)
+	is.Equal("TooManyRequests", TooManyRequests.String())
+	is.Equal(TooManyRequests, ErrorCodeByName["TooManyRequests"])
+	is.Equal(http.StatusTooManyRequests, HTTPStatusFromCode(TooManyRequests))
+	is.Equal(uint32(ResourceExhausted), NewError(TooManyRequests, "", nil).GrpcCode())
+}
@@ -0,0 +1,200 @@
+package ripo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Compressor: returns a writer that compresses data into w
+// all data must be flushed into w when the returned writer is closed
+type Compressor func(w io.Writer) (io.WriteCloser, error)
+
+type compressorEntry struct {
+	encoding string
+	compress Compressor
+}
+
+// registered response compressors, in order of server preference
+var compressors = []*compressorEntry{
+	{
+		encoding: "gzip",
+		compress: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+	},
+}
+
+// RegisterCompressor: add or replace the response compressor for given Content-Encoding
+// for example "br" or "zstd" using a third-party library
+// New encodings have higher priority than existing ones when client accepts both equally
+func RegisterCompressor(encoding string, compressor Compressor) {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if encoding == "" || compressor == nil {
+		panic("RegisterCompressor: empty encoding or nil compressor")
+	}
+	for _, entry := range compressors {
+		if entry.encoding == encoding {
+			entry.compress = compressor
+			return
+		}
+	}
+	compressors = append([]*compressorEntry{{
+		encoding: encoding,
+		compress: compressor,
+	}}, compressors...)
+}
+
+// compressionMinSize: response body smaller than this (in bytes) is not compressed
+var compressionMinSize = 1024
+
+// SetCompressionMinSize: set minimum size of response body (in bytes) to be compressed
+// negative value disables response compression globally
+func SetCompressionMinSize(size int) {
+	compressionMinSize = size
+}
+
+func negotiateCompressor(acceptEncoding string) *compressorEntry {
+	if strings.TrimSpace(acceptEncoding) == "" {
+		return nil
+	}
+	ranges := parseAccept(acceptEncoding)
+	var best *compressorEntry
+	bestQ := 0.0
+	for _, entry := range compressors {
+		q := qualityOf(ranges, entry.encoding)
+		if q > bestQ {
+			best = entry
+			bestQ = q
+		}
+	}
+	return best
+}
+
+// compressResponseBody: compresses body if it's large enough and client accepts a registered encoding
+// sets Content-Encoding and Vary headers and returns the new body
+func compressResponseBody(wh http.Header, acceptEncoding string, body []byte, logger *slog.Logger) []byte {
+	if compressionMinSize < 0 || len(body) < compressionMinSize {
+		return body
+	}
+	if wh.Get("Content-Encoding") != "" {
+		// already encoded by handler
+		return body
+	}
+	addVary(wh, "Accept-Encoding")
+	entry := negotiateCompressor(acceptEncoding)
+	if entry == nil {
+		return body
+	}
+	buf := bytes.NewBuffer(nil)
+	writer, err := entry.compress(buf)
+	if err == nil {
+		_, err = writer.Write(body)
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		logger.Error(
+			"error in compressing response body",
+			slog.String("encoding", entry.encoding),
+			slog.String("error", err.Error()),
+		)
+		return body
+	}
+	wh.Set("Content-Encoding", entry.encoding)
+	etag := wh.Get("Etag")
+	if etag != "" {
+		wh.Set("Etag", etagWithEncoding(etag, entry.encoding))
+	}
+	wh.Del("Content-Length")
+	return buf.Bytes()
+}
+
+func addVary(wh http.Header, key string) {
+	for _, value := range wh.Values("Vary") {
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item == "*" || strings.EqualFold(item, key) {
+				return
+			}
+		}
+	}
+	wh.Add("Vary", key)
+}
+
+// maxDecompressedBodySize: maximum decompressed size of compressed request body in bytes
+// when no body size limit is set, to protect against decompression bombs, 0 means no limit
+var maxDecompressedBodySize int64 = 32 << 20
+
+// SetMaxDecompressedBodySize: set maximum decompressed size of compressed request body in bytes
+// that applies when there is no body size limit (see SetMaxBodySize), 0 means no limit, default is 32 MiB
+func SetMaxDecompressedBodySize(size int64) {
+	if size < 0 {
+		panic("SetMaxDecompressedBodySize: negative size")
+	}
+	maxDecompressedBodySize = size
+}
+
+// decompressors of request body, keyed by Content-Encoding
+var bodyDecompressors = map[string]func(reader io.Reader) (io.Reader, error){
+	"gzip": func(reader io.Reader) (io.Reader, error) {
+		return gzip.NewReader(reader)
+	},
+	"x-gzip": func(reader io.Reader) (io.Reader, error) {
+		return gzip.NewReader(reader)
+	},
+	"deflate": func(reader io.Reader) (io.Reader, error) {
+		// "deflate" in HTTP is zlib format (RFC 1950)
+		return zlib.NewReader(reader)
+	},
+}
+
+// decompressBody: wraps body reader based on Content-Encoding header value
+// returns encoding names (without identity) that are applied, in decoding order
+func decompressBody(reader io.Reader, contentEncoding string) (io.Reader, []string, error) {
+	if contentEncoding == "" {
+		return reader, nil, nil
+	}
+	encodings := strings.Split(contentEncoding, ",")
+	applied := []string{}
+	// encodings are listed in the order they were applied, so we decode in reverse
+	for index := len(encodings) - 1; index >= 0; index-- {
+		encoding := strings.ToLower(strings.TrimSpace(encodings[index]))
+		if encoding == "" || encoding == "identity" {
+			continue
+		}
+		decompressor, ok := bodyDecompressors[encoding]
+		if !ok {
+			return nil, nil, NewError(
+				UnsupportedMediaType,
+				"unsupported content encoding",
+				nil,
+			).Add("contentEncoding", contentEncoding)
+		}
+		var err error
+		reader, err = decompressor(reader)
+		if err == io.EOF {
+			// empty body
+			return strings.NewReader(""), applied, nil
+		}
+		if err != nil {
+			return nil, nil, invalidCompressedBodyError(encoding, err)
+		}
+		applied = append(applied, encoding)
+	}
+	return reader, applied, nil
+}
+
+func invalidCompressedBodyError(encoding string, err error) RPCError {
+	return NewError(
+		InvalidArgument,
+		fmt.Sprintf("request body is not valid %v", encoding),
+		err,
+	)
+}
@@ -0,0 +1,257 @@
+package ripo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+func gzipBytes(data []byte) []byte {
+	buf := bytes.NewBuffer(nil)
+	writer := gzip.NewWriter(buf)
+	_, err := writer.Write(data)
+	if err != nil {
+		panic(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+func zlibBytes(data []byte) []byte {
+	buf := bytes.NewBuffer(nil)
+	writer := zlib.NewWriter(buf)
+	_, err := writer.Write(data)
+	if err != nil {
+		panic(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompressBody_Gzip(t *testing.T) {
+	is := is.New(t)
+	for _, encoding := range []string{"gzip", "x-gzip", "GZIP", "identity, gzip"} {
+		w := doTestRequest(decodingHelloHandler, "POST", "", http.Header{"Content-Encoding": {encoding}}, string(gzipBytes([]byte(`{"name": "John"}`))))
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("{\"msg\":\"hello John\"}", w.Body.String())
+	}
+}
+
+func TestDecompressBody_Deflate(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(decodingHelloHandler, "POST", "", http.Header{"Content-Encoding": {"deflate"}}, string(zlibBytes([]byte(`{"name": "John"}`))))
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal("{\"msg\":\"hello John\"}", w.Body.String())
+}
+
+func TestDecompressBody_Multiple(t *testing.T) {
+	is := is.New(t)
+	body := gzipBytes(zlibBytes([]byte(`{"name": "John"}`)))
+	w := doTestRequest(decodingHelloHandler, "POST", "", http.Header{"Content-Encoding": {"deflate, gzip"}}, string(body))
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal("{\"msg\":\"hello John\"}", w.Body.String())
+}
+
+func TestDecompressBody_Limit(t *testing.T) {
+	is := is.New(t)
+	bomb := gzipBytes([]byte(`{"name": "` + strings.Repeat("A", 100000) + `"}`))
+	is.True(len(bomb) < 1000)
+	w := doTestRequest(decodingHelloHandler, "POST", "", http.Header{"Content-Encoding": {"gzip"}}, string(bomb), MaxBodySize(1000))
+	is.Equal(http.StatusRequestEntityTooLarge, w.Code)
+	is.Equal(
+		"{\"code\":\"PayloadTooLarge\",\"error\":\"request body is too large, must be at most 1000 bytes\"}",
+		w.Body.String(),
+	)
+}
+
+func TestDecompressBody_DefaultLimit(t *testing.T) {
+	is := is.New(t)
+	is.Equal(int64(0), maxBodySize)
+	// highly compressible payload, no MaxBodySize
+	bomb := gzipBytes([]byte(`{"name": "` + strings.Repeat("A", int(maxDecompressedBodySize)) + `"}`))
+	is.True(len(bomb) < 100000)
+	w := doTestRequest(decodingHelloHandler, "POST", "", http.Header{"Content-Encoding": {"gzip"}}, string(bomb))
+	is.Equal(http.StatusRequestEntityTooLarge, w.Code)
+	is.Equal(
+		"{\"code\":\"PayloadTooLarge\",\"error\":\"request body is too large, must be at most 33554432 bytes\"}",
+		w.Body.String(),
+	)
+	{
+		defer SetMaxDecompressedBodySize(maxDecompressedBodySize)
+		SetMaxDecompressedBodySize(0)
+		w := doTestRequest(decodingHelloHandler, "POST", "", http.Header{"Content-Encoding": {"gzip"}}, string(gzipBytes([]byte(`{"name": "John"}`))))
+		is.Equal(http.StatusOK, w.Code)
+	}
+	is.ShouldPanic(func() {
+		SetMaxDecompressedBodySize(-1)
+	})
+}
+
+func TestDecompressBody_Errors(t *testing.T) {
+	is := is.New(t)
+	{
+		w := doTestRequest(decodingHelloHandler, "POST", "", http.Header{"Content-Encoding": {"br"}}, `{"name": "John"}`)
+		is.Equal(http.StatusUnsupportedMediaType, w.Code)
+		is.Equal("{\"code\":\"UnsupportedMediaType\",\"error\":\"unsupported content encoding\"}", w.Body.String())
+	}
+	{
+		w := doTestRequest(decodingHelloHandler, "POST", "", http.Header{"Content-Encoding": {"gzip"}}, `{"name": "John"}`)
+		is.Equal(http.StatusBadRequest, w.Code)
+		is.Equal("{\"code\":\"InvalidArgument\",\"error\":\"request body is not valid gzip\"}", w.Body.String())
+	}
+	{
+		// truncated data
+		body := gzipBytes([]byte(`{"name": "John"}`))
+		w := doTestRequest(decodingHelloHandler, "POST", "", http.Header{"Content-Encoding": {"gzip"}}, string(body[:len(body)-10]))
+		is.Equal(http.StatusBadRequest, w.Code)
+		is.Equal("{\"code\":\"InvalidArgument\",\"error\":\"request body is not valid gzip\"}", w.Body.String())
+	}
+	{
+		w := doTestRequest(decodingHelloHandler, "POST", "", http.Header{"Content-Encoding": {"gzip"}}, "")
+		is.Equal(http.StatusBadRequest, w.Code)
+		is.Equal("{\"code\":\"MissingArgument\",\"error\":\"missing 'name'\"}", w.Body.String())
+	}
+}
+
+// reverseWriter: a fake compressor that reverses the data
+type reverseWriter struct {
+	w    io.Writer
+	data []byte
+}
+
+func (rw *reverseWriter) Write(p []byte) (int, error) {
+	rw.data = append(rw.data, p...)
+	return len(p), nil
+}
+
+func (rw *reverseWriter) Close() error {
+	out := make([]byte, len(rw.data))
+	for index, b := range rw.data {
+		out[len(out)-1-index] = b
+	}
+	_, err := rw.w.Write(out)
+	return err
+}
+
+func largeListHandler(req Request) (*Response, error) {
+	items := make([]string, 500)
+	for index := range items {
+		items[index] = "item"
+	}
+	return &Response{Data: items}, nil
+}
+
+func TestCompressResponse_Gzip(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(largeListHandler, "POST", "", http.Header{"Accept-Encoding": {"gzip, deflate"}}, "")
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal("gzip", w.Header().Get("Content-Encoding"))
+	is.Equal([]string{"Accept", "Accept-Encoding"}, w.Header().Values("Vary"))
+	is.Equal("application/json; charset=UTF-8", w.Header().Get("Content-Type"))
+	reader, err := gzip.NewReader(w.Body)
+	is.NotErr(err)
+	body, err := io.ReadAll(reader)
+	is.NotErr(err)
+	is.Equal(`["item",`, string(body[:8]))
+	is.Equal(500*7+1, len(body))
+}
+
+func TestCompressResponse_Preference(t *testing.T) {
+	is := is.New(t)
+	defer func(saved []*compressorEntry) {
+		compressors = saved
+	}(compressors)
+	RegisterCompressor("x-test-reverse", func(w io.Writer) (io.WriteCloser, error) {
+		return &reverseWriter{w: w}, nil
+	})
+	{
+		// registered compressor has higher priority
+		w := doTestRequest(largeListHandler, "POST", "", http.Header{"Accept-Encoding": {"*"}}, "")
+		is.Equal("x-test-reverse", w.Header().Get("Content-Encoding"))
+		is.Equal(`]"meti"`, w.Body.String()[:7])
+	}
+	{
+		w := doTestRequest(largeListHandler, "POST", "", http.Header{"Accept-Encoding": {"x-test-reverse;q=0.5, gzip"}}, "")
+		is.Equal("gzip", w.Header().Get("Content-Encoding"))
+	}
+	{
+		w := doTestRequest(largeListHandler, "POST", "", http.Header{"Accept-Encoding": {"gzip;q=0, *;q=0.1"}}, "")
+		is.Equal("x-test-reverse", w.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestCompressResponse_NotCompressed(t *testing.T) {
+	is := is.New(t)
+	{
+		w := doTestRequest(largeListHandler, "POST", "", nil, "")
+		is.Equal("", w.Header().Get("Content-Encoding"))
+		is.Equal([]string{"Accept", "Accept-Encoding"}, w.Header().Values("Vary"))
+		is.Equal(`["item",`, w.Body.String()[:8])
+	}
+	{
+		w := doTestRequest(largeListHandler, "POST", "", http.Header{"Accept-Encoding": {"br"}}, "")
+		is.Equal("", w.Header().Get("Content-Encoding"))
+		is.Equal([]string{"Accept", "Accept-Encoding"}, w.Header().Values("Vary"))
+	}
+	{
+		// too small
+		w := doTestRequest(func(req Request) (*Response, error) {
+			return &Response{Data: []string{"item"}}, nil
+		}, "POST", "", http.Header{"Accept-Encoding": {"gzip"}}, "")
+		is.Equal("", w.Header().Get("Content-Encoding"))
+		is.Equal([]string{"Accept"}, w.Header().Values("Vary"))
+		is.Equal(`["item"]`, w.Body.String())
+	}
+	{
+		w := doTestRequest(largeListHandler, "POST", "", http.Header{"Accept-Encoding": {"gzip"}}, "", DisableCompression())
+		is.Equal("", w.Header().Get("Content-Encoding"))
+		is.Equal(`["item",`, w.Body.String()[:8])
+	}
+	{
+		w := doTestRequest(func(req Request) (*Response, error) {
+			res, _ := largeListHandler(req)
+			res.DisableCompression = true
+			return res, nil
+		}, "POST", "", http.Header{"Accept-Encoding": {"gzip"}}, "")
+		is.Equal("", w.Header().Get("Content-Encoding"))
+		is.Equal(`["item",`, w.Body.String()[:8])
+	}
+	{
+		w := doTestRequest(func(req Request) (*Response, error) {
+			res, _ := largeListHandler(req)
+			res.Header = http.Header{"Vary": []string{"Origin, accept-encoding"}}
+			return res, nil
+		}, "POST", "", http.Header{"Accept-Encoding": {"gzip"}}, "")
+		is.Equal("gzip", w.Header().Get("Content-Encoding"))
+		is.Equal([]string{"Origin, accept-encoding", "Accept"}, w.Header().Values("Vary"))
+	}
+}
+
+func TestSetCompressionMinSize(t *testing.T) {
+	is := is.New(t)
+	defer SetCompressionMinSize(compressionMinSize)
+	SetCompressionMinSize(-1)
+	{
+		w := doTestRequest(largeListHandler, "POST", "", http.Header{"Accept-Encoding": {"gzip"}}, "")
+		is.Equal("", w.Header().Get("Content-Encoding"))
+	}
+	SetCompressionMinSize(1)
+	{
+		w := doTestRequest(func(req Request) (*Response, error) {
+			return &Response{Data: []string{"item"}}, nil
+		}, "POST", "", http.Header{"Accept-Encoding": {"gzip"}}, "")
+		is.Equal("gzip", w.Header().Get("Content-Encoding"))
+	}
+}
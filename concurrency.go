@@ -0,0 +1,257 @@
+package ripo
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConcurrencyRetryAfter = time.Second
+
+	// aimdBackoffRatio: multiplier of limit when latency is above target
+	aimdBackoffRatio = 0.9
+)
+
+type concurrencyWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// ConcurrencyLimiter: limits number of requests that are handled at the same time
+// excess requests wait in a bounded queue (in FIFO order) up to queue timeout, and
+// are rejected with Unavailable error and Retry-After header when queue is full or timeout is reached
+type ConcurrencyLimiter struct {
+	maxQueue     int
+	queueTimeout time.Duration
+	retryAfter   time.Duration
+
+	// adaptive (AIMD) limit, disabled if targetLatency is 0
+	minLimit      int
+	maxLimit      int
+	targetLatency time.Duration
+
+	mutex        sync.Mutex
+	limit        int
+	inFlight     int
+	queue        []*concurrencyWaiter
+	fastCount    int
+	lastDecrease time.Time
+}
+
+// NewConcurrencyLimiter: allows limit concurrent requests, and up to maxQueue waiting requests
+// for at most queueTimeout, maxQueue=0 means excess requests are rejected immediately
+func NewConcurrencyLimiter(limit int, maxQueue int, queueTimeout time.Duration) *ConcurrencyLimiter {
+	if limit < 1 {
+		panic("NewConcurrencyLimiter: limit must be positive")
+	}
+	if maxQueue < 0 {
+		panic("NewConcurrencyLimiter: negative maxQueue")
+	}
+	if maxQueue > 0 && queueTimeout <= 0 {
+		panic("NewConcurrencyLimiter: queueTimeout must be positive")
+	}
+	return &ConcurrencyLimiter{
+		limit:        limit,
+		maxQueue:     maxQueue,
+		queueTimeout: queueTimeout,
+		retryAfter:   defaultConcurrencyRetryAfter,
+	}
+}
+
+// Adaptive: adjust limit between minLimit and maxLimit based on latency of requests (AIMD)
+// limit is increased by 1 after each limit requests faster than targetLatency, and multiplied
+// by 0.9 (at most once per targetLatency) when a request is slower than targetLatency
+func (l *ConcurrencyLimiter) Adaptive(minLimit int, maxLimit int, targetLatency time.Duration) *ConcurrencyLimiter {
+	if minLimit < 1 || maxLimit < minLimit {
+		panic("ConcurrencyLimiter.Adaptive: invalid minLimit or maxLimit")
+	}
+	if targetLatency <= 0 {
+		panic("ConcurrencyLimiter.Adaptive: targetLatency must be positive")
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.minLimit = minLimit
+	l.maxLimit = maxLimit
+	l.targetLatency = targetLatency
+	l.limit = min(max(l.limit, minLimit), maxLimit)
+	return l
+}
+
+// RetryAfter: set value of Retry-After header of rejected requests, default is 1 second
+func (l *ConcurrencyLimiter) RetryAfter(retryAfter time.Duration) *ConcurrencyLimiter {
+	if retryAfter <= 0 {
+		panic("ConcurrencyLimiter.RetryAfter: retryAfter must be positive")
+	}
+	l.retryAfter = retryAfter
+	return l
+}
+
+// Limit: returns current limit
+func (l *ConcurrencyLimiter) Limit() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.limit
+}
+
+// InFlight: returns number of requests that are being handled
+func (l *ConcurrencyLimiter) InFlight() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.inFlight
+}
+
+func (l *ConcurrencyLimiter) overloadedError() RPCError {
+	return NewError(
+		Unavailable, "server is overloaded", nil,
+	).AddPublic(
+		"retryAfter", int(math.Ceil(l.retryAfter.Seconds())),
+	).Add("limit", l.limit).Add("queue", len(l.queue))
+}
+
+// acquire: waits for a slot, returned function releases the slot and must be called once
+func (l *ConcurrencyLimiter) acquire(ctx context.Context) (func(), error) {
+	l.mutex.Lock()
+	if l.inFlight < l.limit && len(l.queue) == 0 {
+		l.inFlight++
+		l.mutex.Unlock()
+		return l.releaseFunc(), nil
+	}
+	if len(l.queue) >= l.maxQueue {
+		err := l.overloadedError()
+		l.mutex.Unlock()
+		return nil, err
+	}
+	waiter := &concurrencyWaiter{ready: make(chan struct{})}
+	l.queue = append(l.queue, waiter)
+	l.mutex.Unlock()
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-waiter.ready:
+		return l.releaseFunc(), nil
+	case <-timer.C:
+	case <-ctx.Done():
+		err = NewError(Canceled, "", ctx.Err())
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if waiter.granted {
+		// granted at the same time
+		return l.releaseFunc(), nil
+	}
+	for index, other := range l.queue {
+		if other == waiter {
+			l.queue = append(l.queue[:index], l.queue[index+1:]...)
+			break
+		}
+	}
+	if err == nil {
+		err = l.overloadedError().Add("queueTimeout", l.queueTimeout.String())
+	}
+	return nil, err
+}
+
+func (l *ConcurrencyLimiter) releaseFunc() func() {
+	start := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.release(time.Since(start))
+		})
+	}
+}
+
+func (l *ConcurrencyLimiter) release(latency time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.inFlight--
+	if l.targetLatency > 0 {
+		l.adjust(latency, time.Now())
+	}
+	for l.inFlight < l.limit && len(l.queue) > 0 {
+		waiter := l.queue[0]
+		l.queue = l.queue[1:]
+		waiter.granted = true
+		l.inFlight++
+		close(waiter.ready)
+	}
+}
+
+// adjust: updates adaptive limit, mutex must be locked
+func (l *ConcurrencyLimiter) adjust(latency time.Duration, now time.Time) {
+	if latency > l.targetLatency {
+		l.fastCount = 0
+		if now.Sub(l.lastDecrease) >= l.targetLatency {
+			l.limit = max(l.minLimit, int(float64(l.limit)*aimdBackoffRatio))
+			l.lastDecrease = now
+		}
+		return
+	}
+	l.fastCount++
+	if l.fastCount >= l.limit {
+		l.fastCount = 0
+		l.limit = min(l.limit+1, l.maxLimit)
+	}
+}
+
+// concurrencyLimiter: global concurrency limiter, nil means no limit
+var concurrencyLimiter *ConcurrencyLimiter
+
+// SetConcurrencyLimiter: set global concurrency limiter, that is shared by all handlers
+// nil means no global limit, handlers can be excluded with DisableConcurrencyLimit option
+func SetConcurrencyLimiter(l *ConcurrencyLimiter) {
+	concurrencyLimiter = l
+}
+
+// ConcurrencyLimit: limit concurrent requests of this handler with l, in addition to global limiter
+func ConcurrencyLimit(l *ConcurrencyLimiter) HandlerOption {
+	return func(config *handlerConfig) {
+		config.concurrencyLimiter = l
+	}
+}
+
+// DisableConcurrencyLimit: do not apply global concurrency limiter to this handler
+func DisableConcurrencyLimit() HandlerOption {
+	return func(config *handlerConfig) {
+		config.disableGlobalConcurrencyLimit = true
+	}
+}
+
+// acquireConcurrency: acquires slots of global and handler concurrency limiters, in this order
+// sub-requests of a batch do not acquire global slots, since the batch request holds one
+// sets Retry-After header if request is rejected
+// returned function releases the slots, and must be called after response is written
+func acquireConcurrency(w http.ResponseWriter, request ExtendedRequest, config *handlerConfig) (func(), error) {
+	limiters := []*ConcurrencyLimiter{}
+	isBatchItem := request.Context().Value(batchContextKey{}) != nil
+	if concurrencyLimiter != nil && !config.disableGlobalConcurrencyLimit && !isBatchItem {
+		limiters = append(limiters, concurrencyLimiter)
+	}
+	if config.concurrencyLimiter != nil {
+		limiters = append(limiters, config.concurrencyLimiter)
+	}
+	releases := make([]func(), 0, len(limiters))
+	releaseAll := func() {
+		for index := len(releases) - 1; index >= 0; index-- {
+			releases[index]()
+		}
+	}
+	for _, limiter := range limiters {
+		release, err := limiter.acquire(request.Context())
+		if err != nil {
+			releaseAll()
+			if rpcErr, ok := err.(RPCError); ok && rpcErr.Code() == Unavailable {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limiter.retryAfter.Seconds()))))
+			}
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+	return releaseAll, nil
+}
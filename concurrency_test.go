@@ -0,0 +1,192 @@
+package ripo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ilius/is/v2"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	is := is.New(t)
+	limiter := NewConcurrencyLimiter(1, 1, time.Second).RetryAfter(2 * time.Second)
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	handlerFunc := TranslateHandler(func(req Request) (*Response, error) {
+		started <- struct{}{}
+		<-release
+		return NoContent(), nil
+	}, ConcurrencyLimit(limiter))
+	codes := make(chan int, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			handlerFunc(w, httptest.NewRequest("GET", "/", nil))
+			codes <- w.Code
+		}()
+	}
+	<-started
+	// wait for the second request to be queued
+	for {
+		limiter.mutex.Lock()
+		queued := len(limiter.queue)
+		limiter.mutex.Unlock()
+		if queued == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	is.Equal(1, limiter.InFlight())
+	{
+		// queue is full
+		w := httptest.NewRecorder()
+		handlerFunc(w, httptest.NewRequest("GET", "/", nil))
+		is.Equal(http.StatusServiceUnavailable, w.Code)
+		is.Equal("2", w.Header().Get("Retry-After"))
+		body := map[string]any{}
+		is.NotErr(json.Unmarshal(w.Body.Bytes(), &body))
+		is.Equal("Unavailable", body["code"])
+		is.Equal("server is overloaded", body["error"])
+		is.Equal(map[string]any{"retryAfter": float64(2)}, body["details"])
+	}
+	close(release)
+	wg.Wait()
+	is.Equal(http.StatusNoContent, <-codes)
+	is.Equal(http.StatusNoContent, <-codes)
+	is.Equal(1, len(started)) // first one is received above
+	is.Equal(0, limiter.InFlight())
+}
+
+func TestConcurrencyLimit_QueueTimeout(t *testing.T) {
+	is := is.New(t)
+	limiter := NewConcurrencyLimiter(1, 5, 10*time.Millisecond)
+	release, err := limiter.acquire(context.Background())
+	is.NotErr(err)
+	{
+		_, err := limiter.acquire(context.Background())
+		AssertError(t, err, Unavailable, "server is overloaded")
+		is.Equal("10ms", err.(RPCError).Details()["queueTimeout"])
+	}
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := limiter.acquire(ctx)
+		AssertError(t, err, Canceled, "Canceled")
+	}
+	is.Equal(0, len(limiter.queue))
+	release()
+	release() // no-op
+	is.Equal(0, limiter.InFlight())
+}
+
+func TestConcurrencyLimit_NoQueue(t *testing.T) {
+	is := is.New(t)
+	limiter := NewConcurrencyLimiter(1, 0, 0)
+	release, err := limiter.acquire(context.Background())
+	is.NotErr(err)
+	_, err = limiter.acquire(context.Background())
+	AssertError(t, err, Unavailable, "server is overloaded")
+	release()
+	release, err = limiter.acquire(context.Background())
+	is.NotErr(err)
+	release()
+}
+
+func TestConcurrencyLimit_Global(t *testing.T) {
+	is := is.New(t)
+	limiter := NewConcurrencyLimiter(1, 0, 0)
+	SetConcurrencyLimiter(limiter)
+	defer SetConcurrencyLimiter(nil)
+	release, err := limiter.acquire(context.Background())
+	is.NotErr(err)
+	defer release()
+	handler := func(req Request) (*Response, error) {
+		return NoContent(), nil
+	}
+	{
+		w := httptest.NewRecorder()
+		TranslateHandler(handler)(w, httptest.NewRequest("GET", "/", nil))
+		is.Equal(http.StatusServiceUnavailable, w.Code)
+		is.Equal("1", w.Header().Get("Retry-After"))
+	}
+	{
+		w := httptest.NewRecorder()
+		TranslateHandler(handler, DisableConcurrencyLimit())(w, httptest.NewRequest("GET", "/", nil))
+		is.Equal(http.StatusNoContent, w.Code)
+	}
+	{
+		// slot of global limiter is released if handler limiter rejects
+		handlerLimiter := NewConcurrencyLimiter(1, 0, 0)
+		handlerRelease, err := handlerLimiter.acquire(context.Background())
+		is.NotErr(err)
+		defer handlerRelease()
+		release()
+		w := httptest.NewRecorder()
+		TranslateHandler(handler, ConcurrencyLimit(handlerLimiter))(w, httptest.NewRequest("GET", "/", nil))
+		is.Equal(http.StatusServiceUnavailable, w.Code)
+		is.Equal(0, limiter.InFlight())
+	}
+}
+
+func TestConcurrencyLimit_Batch(t *testing.T) {
+	is := is.New(t)
+	limiter := NewConcurrencyLimiter(1, 10, 200*time.Millisecond)
+	SetConcurrencyLimiter(limiter)
+	defer SetConcurrencyLimiter(nil)
+	w := doTestRequest(newBatchTestMux(), "POST", "/batch", batchTestHeader, `{"requests": [{"id": "1", "method": "GET", "path": "/text"}]}`)
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal(`{"responses":[{"id":"1","status":200,"headers":{"Content-Type":"text/plain; charset=utf-8"},"body":"plain text"}]}`, w.Body.String())
+	is.Equal(0, limiter.InFlight())
+}
+
+func TestConcurrencyLimit_Adaptive(t *testing.T) {
+	is := is.New(t)
+	limiter := NewConcurrencyLimiter(4, 0, 0).Adaptive(2, 5, 100*time.Millisecond)
+	now := time.Unix(1000, 0)
+	for i := 0; i < 4; i++ {
+		limiter.adjust(10*time.Millisecond, now)
+	}
+	is.Equal(5, limiter.Limit())
+	for i := 0; i < 10; i++ {
+		limiter.adjust(10*time.Millisecond, now)
+	}
+	is.Equal(5, limiter.Limit()) // maxLimit
+	limiter.adjust(time.Second, now)
+	is.Equal(4, limiter.Limit())
+	// at most once per target latency
+	limiter.adjust(time.Second, now.Add(50*time.Millisecond))
+	is.Equal(4, limiter.Limit())
+	limiter.adjust(time.Second, now.Add(100*time.Millisecond))
+	is.Equal(3, limiter.Limit())
+	limiter.adjust(time.Second, now.Add(200*time.Millisecond))
+	is.Equal(2, limiter.Limit())
+	limiter.adjust(time.Second, now.Add(300*time.Millisecond))
+	is.Equal(2, limiter.Limit()) // minLimit
+}
+
+func TestConcurrencyLimiter_Invalid(t *testing.T) {
+	is := is.New(t)
+	is.ShouldPanic(func() {
+		NewConcurrencyLimiter(0, 0, 0)
+	})
+	is.ShouldPanic(func() {
+		NewConcurrencyLimiter(1, -1, 0)
+	})
+	is.ShouldPanic(func() {
+		NewConcurrencyLimiter(1, 1, 0)
+	})
+	is.ShouldPanic(func() {
+		NewConcurrencyLimiter(1, 0, 0).Adaptive(3, 2, time.Second)
+	})
+	is.ShouldPanic(func() {
+		NewConcurrencyLimiter(1, 0, 0).RetryAfter(0)
+	})
+}
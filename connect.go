@@ -0,0 +1,186 @@
+package ripo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const connectContentType = "application/json"
+
+// connectCodes: Connect protocol code name and HTTP status, indexed by gRPC code
+var connectCodes = [...]struct {
+	name   string
+	status int
+}{
+	Canceled:           {"canceled", 499},
+	Unknown:            {"unknown", http.StatusInternalServerError},
+	InvalidArgument:    {"invalid_argument", http.StatusBadRequest},
+	DeadlineExceeded:   {"deadline_exceeded", http.StatusGatewayTimeout},
+	NotFound:           {"not_found", http.StatusNotFound},
+	AlreadyExists:      {"already_exists", http.StatusConflict},
+	PermissionDenied:   {"permission_denied", http.StatusForbidden},
+	ResourceExhausted:  {"resource_exhausted", http.StatusTooManyRequests},
+	FailedPrecondition: {"failed_precondition", http.StatusBadRequest},
+	Aborted:            {"aborted", http.StatusConflict},
+	OutOfRange:         {"out_of_range", http.StatusBadRequest},
+	Unimplemented:      {"unimplemented", http.StatusNotImplemented},
+	Internal:           {"internal", http.StatusInternalServerError},
+	Unavailable:        {"unavailable", http.StatusServiceUnavailable},
+	DataLoss:           {"data_loss", http.StatusInternalServerError},
+	Unauthenticated:    {"unauthenticated", http.StatusUnauthorized},
+}
+
+type connectErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// ConnectHandler: serves handlers over Connect unary protocol with json codec
+// procedures keys are procedure paths like "/pkg.Service/Method", request message is
+// exposed as request body, and Data of response is encoded as json response message
+// Connect-Timeout-Ms header is applied as deadline of req.Context()
+// errors are sent as Connect error json, with code based on GrpcCode()
+func ConnectHandler(procedures map[string]Handler, options ...HandlerOption) http.HandlerFunc {
+	config := newHandlerConfig(options)
+	handlers := make(map[string]Handler, len(procedures))
+	for procedure, handler := range procedures {
+		handlers["/"+strings.TrimPrefix(procedure, "/")] = handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w, request, finish := startRequest(w, r, config, "ConnectHandler")
+		defer finish()
+		r = request.r
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		contentType := strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0])
+		if contentType != connectContentType {
+			w.Header().Set("Accept-Post", connectContentType)
+			http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		handler, ok := handlers[r.URL.Path]
+		handlerName := r.URL.Path
+		if ok {
+			handlerName = handlerFunctionName(handler)
+		}
+		request.handlerName = handlerName
+		ctx, cancel, timeoutErr := connectContext(r)
+		defer cancel()
+		request.r = r.WithContext(ctx)
+		var err error
+		switch {
+		case timeoutErr != nil:
+			err = timeoutErr
+		case r.Header.Get("Connect-Protocol-Version") != "" && r.Header.Get("Connect-Protocol-Version") != "1":
+			err = NewError(
+				InvalidArgument, "unsupported connect protocol version", nil,
+			).Add("version", r.Header.Get("Connect-Protocol-Version"))
+		case !ok:
+			err = NewError(Unimplemented, fmt.Sprintf("procedure %v is not implemented", r.URL.Path), nil)
+		}
+		if err != nil {
+			handleConnectError(err, w, request)
+			return
+		}
+		release, err := applyLimits(w, request, config)
+		if err != nil {
+			handleConnectError(err, w, request)
+			return
+		}
+		defer release()
+		res, err := callHandler(handler, request)
+		if res == nil && err == nil {
+			err = NewError(Internal, "", fmt.Errorf("handler %v returned nil response with nil error", handlerName))
+		}
+		var message []byte
+		if err == nil {
+			message, err = encodeConnectMessage(res)
+		}
+		if err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				err = NewError(DeadlineExceeded, "", err)
+			}
+			handleConnectError(err, w, request)
+			return
+		}
+		writeResponse(w, &Response{
+			Data:               message,
+			Header:             withContentType(res.Header, connectContentType),
+			Cookies:            res.Cookies,
+			DisableCompression: res.DisableCompression,
+		}, request, config)
+	}
+}
+
+// connectContext: applies Connect-Timeout-Ms header to request context
+func connectContext(r *http.Request) (context.Context, context.CancelFunc, error) {
+	timeoutStr := r.Header.Get("Connect-Timeout-Ms")
+	if timeoutStr == "" {
+		ctx, cancel := context.WithCancel(r.Context())
+		return ctx, cancel, nil
+	}
+	timeoutMs, err := strconv.ParseInt(timeoutStr, 10, 64)
+	if err != nil || timeoutMs < 0 || len(timeoutStr) > 10 {
+		ctx, cancel := context.WithCancel(r.Context())
+		return ctx, cancel, NewError(
+			InvalidArgument, "invalid Connect-Timeout-Ms header", err,
+		).Add("timeout", timeoutStr)
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(timeoutMs)*time.Millisecond)
+	return ctx, cancel, nil
+}
+
+func encodeConnectMessage(res *Response) ([]byte, error) {
+	_, isFile := res.Data.(*File)
+	if isFile || isStreamData(res.Data) {
+		return nil, NewError(Internal, "", fmt.Errorf("unsupported response data type %T for connect", res.Data))
+	}
+	message, err := encodeJSON(res.Data)
+	if err != nil {
+		return nil, NewError(Internal, "", err)
+	}
+	return message, nil
+}
+
+func withContentType(header http.Header, contentType string) http.Header {
+	newHeader := header.Clone()
+	if newHeader == nil {
+		newHeader = http.Header{}
+	}
+	newHeader.Set("Content-Type", contentType)
+	return newHeader
+}
+
+func handleConnectError(err error, w http.ResponseWriter, request *requestImp) {
+	rpcErr := asRPCError(err, request.Logger())
+	addRequestDetails(rpcErr, request)
+	grpcCode := rpcErr.GrpcCode()
+	if grpcCode == 0 || int(grpcCode) >= len(connectCodes) {
+		grpcCode = uint32(Unknown)
+	}
+	connectCode := connectCodes[grpcCode]
+	setObservedCode(w, rpcErr.Code())
+	body := &connectErrorBody{
+		Code:    connectCode.name,
+		Message: rpcErr.Message(),
+	}
+	bodyBytes, _ := json.Marshal(body)
+	wh := w.Header()
+	wh.Set("Content-Type", connectContentType)
+	w.WriteHeader(connectCode.status)
+	_, writeErr := w.Write(bodyBytes)
+	if writeErr != nil {
+		request.Logger().Warn("error in writing error response", slog.String("error", writeErr.Error()))
+	}
+	errorDispatcher(request, rpcErr)
+}
@@ -0,0 +1,135 @@
+package ripo
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ilius/is/v2"
+)
+
+var connectTestProcedures = map[string]Handler{
+	"/greet.v1.GreetService/Greet": func(req Request) (*Response, error) {
+		name, err := req.GetString("name", FromBody)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{
+			Data:   map[string]string{"greeting": "Hello " + *name},
+			Header: http.Header{"X-Greeter": []string{"ripo"}},
+		}, nil
+	},
+	"greet.v1.GreetService/Fail": func(req Request) (*Response, error) {
+		return nil, NewError(ResourceLocked, "resource is locked", nil)
+	},
+	"/greet.v1.GreetService/Empty": func(req Request) (*Response, error) {
+		return &Response{}, nil
+	},
+	"/greet.v1.GreetService/Slow": func(req Request) (*Response, error) {
+		_, hasDeadline := req.Context().Deadline()
+		if !hasDeadline {
+			return nil, NewError(Internal, "no deadline", nil)
+		}
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	},
+}
+
+// connectTestHeader: returns header of a Connect unary request, with given header added
+func connectTestHeader(header http.Header) http.Header {
+	connectHeader := http.Header{
+		"Content-Type":             {"application/json"},
+		"Connect-Protocol-Version": {"1"},
+	}
+	for key, values := range header {
+		connectHeader[key] = values
+	}
+	return connectHeader
+}
+
+func TestConnect_Unary(t *testing.T) {
+	is := is.New(t)
+	{
+		w := doTestRequest(ConnectHandler(connectTestProcedures), "POST", "/greet.v1.GreetService/Greet", connectTestHeader(nil), `{"name":"John"}`)
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("application/json", w.Header().Get("Content-Type"))
+		is.Equal("ripo", w.Header().Get("X-Greeter"))
+		is.Equal(`{"greeting":"Hello John"}`, w.Body.String())
+	}
+	{
+		w := doTestRequest(ConnectHandler(connectTestProcedures), "POST", "/greet.v1.GreetService/Empty", connectTestHeader(nil), `{}`)
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal(`{}`, w.Body.String())
+	}
+}
+
+func TestConnect_Errors(t *testing.T) {
+	is := is.New(t)
+	{
+		w := doTestRequest(ConnectHandler(connectTestProcedures), "POST", "/greet.v1.GreetService/Greet", connectTestHeader(nil), `{}`)
+		is.Equal(http.StatusBadRequest, w.Code)
+		is.Equal("application/json", w.Header().Get("Content-Type"))
+		is.Equal(`{"code":"invalid_argument","message":"missing 'name'"}`, w.Body.String())
+	}
+	{
+		w := doTestRequest(ConnectHandler(connectTestProcedures), "POST", "/greet.v1.GreetService/Fail", connectTestHeader(nil), `{}`)
+		is.Equal(http.StatusConflict, w.Code)
+		is.Equal(`{"code":"aborted","message":"resource is locked"}`, w.Body.String())
+	}
+	{
+		w := doTestRequest(ConnectHandler(connectTestProcedures), "POST", "/greet.v1.GreetService/Missing", connectTestHeader(nil), `{}`)
+		is.Equal(http.StatusNotImplemented, w.Code)
+		is.Equal(`{"code":"unimplemented","message":"procedure /greet.v1.GreetService/Missing is not implemented"}`, w.Body.String())
+	}
+	{
+		w := doTestRequest(ConnectHandler(connectTestProcedures), "POST", "/greet.v1.GreetService/Greet", connectTestHeader(http.Header{
+			"Connect-Protocol-Version": []string{"2"},
+		}), `{}`)
+		is.Equal(http.StatusBadRequest, w.Code)
+		is.Equal(`{"code":"invalid_argument","message":"unsupported connect protocol version"}`, w.Body.String())
+	}
+	{
+		w := doTestRequest(ConnectHandler(connectTestProcedures), "POST", "/greet.v1.GreetService/Greet", connectTestHeader(http.Header{
+			"Content-Type": []string{"application/proto"},
+		}), `{}`)
+		is.Equal(http.StatusUnsupportedMediaType, w.Code)
+		is.Equal("application/json", w.Header().Get("Accept-Post"))
+	}
+	{
+		w := doTestRequest(ConnectHandler(connectTestProcedures), "GET", "/greet.v1.GreetService/Greet", nil, "")
+		is.Equal(http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestConnect_Timeout(t *testing.T) {
+	is := is.New(t)
+	{
+		start := time.Now()
+		w := doTestRequest(ConnectHandler(connectTestProcedures), "POST", "/greet.v1.GreetService/Slow", connectTestHeader(http.Header{
+			"Connect-Timeout-Ms": []string{"20"},
+		}), `{}`)
+		is.True(time.Since(start) < time.Second)
+		is.Equal(http.StatusGatewayTimeout, w.Code)
+		is.Equal(`{"code":"deadline_exceeded"}`, w.Body.String())
+	}
+	{
+		w := doTestRequest(ConnectHandler(connectTestProcedures), "POST", "/greet.v1.GreetService/Slow", connectTestHeader(http.Header{
+			"Connect-Timeout-Ms": []string{"-1"},
+		}), `{}`)
+		is.Equal(http.StatusBadRequest, w.Code)
+		is.Equal(`{"code":"invalid_argument","message":"invalid Connect-Timeout-Ms header"}`, w.Body.String())
+	}
+}
+
+func TestConnect_ErrorDispatcher(t *testing.T) {
+	is := is.New(t)
+	var dispatched RPCError
+	SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {
+		dispatched = rpcErr
+	})
+	defer SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {})
+	w := doTestRequest(ConnectHandler(connectTestProcedures), "POST", "/greet.v1.GreetService/Fail", connectTestHeader(nil), "{}")
+	is.Equal(http.StatusConflict, w.Code)
+	is.NotNil(dispatched)
+	is.Equal(ResourceLocked, dispatched.Code())
+}
@@ -0,0 +1,158 @@
+package ripo
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+// Decoder: decodes request body into model, which is a pointer to a struct
+// or a pointer to map[string]any (for BodyMap, and so FromBody)
+type Decoder func(body []byte, model any) error
+
+type decoderEntry struct {
+	name   string // used in error message
+	decode Decoder
+}
+
+var decoders = map[string]*decoderEntry{
+	"application/json": {name: "json", decode: json.Unmarshal},
+	"application/xml":  {name: "xml", decode: decodeXML},
+	"text/xml":         {name: "xml", decode: decodeXML},
+
+	// body is already consumed by r.ParseForm, params are available with FromForm
+	"application/x-www-form-urlencoded": {name: "form", decode: decodeNothing},
+	"multipart/form-data":               {name: "form", decode: decodeNothing},
+}
+
+// RegisterDecoder: add or replace the request body decoder for given media type
+// (Content-Type header without parameters), for example
+// RegisterDecoder("application/msgpack", msgpack.Unmarshal)
+func RegisterDecoder(mediaType string, decoder Decoder) {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "" || decoder == nil {
+		panic("RegisterDecoder: empty media type or nil decoder")
+	}
+	decoders[mediaType] = &decoderEntry{
+		name:   mediaType,
+		decode: decoder,
+	}
+}
+
+// findDecoder: returns decoder for the given Content-Type header value
+// json is used if contentType is empty
+func findDecoder(contentType string) (*decoderEntry, error) {
+	if contentType == "" {
+		return decoders["application/json"], nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, NewError(
+			UnsupportedMediaType,
+			"invalid content type",
+			err,
+		).Add("contentType", contentType)
+	}
+	entry, ok := decoders[mediaType]
+	if ok {
+		return entry, nil
+	}
+	// structured syntax suffixes, like application/problem+json
+	switch {
+	case strings.HasSuffix(mediaType, "+json"):
+		return decoders["application/json"], nil
+	case strings.HasSuffix(mediaType, "+xml"):
+		return decoders["application/xml"], nil
+	}
+	return nil, NewError(
+		UnsupportedMediaType,
+		"unsupported content type",
+		nil,
+	).Add("contentType", contentType)
+}
+
+func decodeNothing(body []byte, model any) error {
+	return nil
+}
+
+// decodeXML: same as xml.Unmarshal, except that it also supports *map[string]any
+// in that case, children of root element are converted to map keys,
+// and elements with the same name are merged into []any
+func decodeXML(body []byte, model any) error {
+	mapPtr, isMap := model.(*map[string]any)
+	if !isMap {
+		return xml.Unmarshal(body, model)
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("xml: no root element")
+			}
+			return err
+		}
+		if _, isStart := token.(xml.StartElement); isStart {
+			break
+		}
+	}
+	value, err := decodeXMLElement(decoder)
+	if err != nil {
+		return err
+	}
+	valueMap, isMap := value.(map[string]any)
+	if !isMap {
+		valueMap = map[string]any{}
+	}
+	if *mapPtr == nil {
+		*mapPtr = valueMap
+		return nil
+	}
+	for key, item := range valueMap {
+		(*mapPtr)[key] = item
+	}
+	return nil
+}
+
+// decodeXMLElement: reads the current element (after its start token) until its end token
+// returns string for text-only elements, and map[string]any for elements with children
+func decodeXMLElement(decoder *xml.Decoder) (any, error) {
+	var children map[string]any
+	text := strings.Builder{}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tokenTyped := token.(type) {
+		case xml.CharData:
+			text.Write(tokenTyped)
+		case xml.StartElement:
+			child, err := decodeXMLElement(decoder)
+			if err != nil {
+				return nil, err
+			}
+			if children == nil {
+				children = map[string]any{}
+			}
+			key := tokenTyped.Name.Local
+			switch existing := children[key].(type) {
+			case nil:
+				children[key] = child
+			case []any:
+				children[key] = append(existing, child)
+			default:
+				children[key] = []any{existing, child}
+			}
+		case xml.EndElement:
+			if children != nil {
+				return children, nil
+			}
+			return strings.TrimSpace(text.String()), nil
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package ripo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+func decodingHelloHandler(req Request) (*Response, error) {
+	name, err := req.GetString("name")
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Data: map[string]string{
+			"msg": "hello " + *name,
+		},
+	}, nil
+}
+
+func TestDecoding_JSON(t *testing.T) {
+	is := is.New(t)
+	for _, contentType := range []string{"", "application/json", "application/json; charset=utf-8", "application/vnd.test+json"} {
+		w := doTestRequest(decodingHelloHandler, "POST", "", http.Header{"Content-Type": {contentType}}, `{"name": "John"}`)
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("{\"msg\":\"hello John\"}", w.Body.String())
+	}
+	w := doTestRequest(decodingHelloHandler, "POST", "", http.Header{"Content-Type": {"application/json"}}, `<name>John</name>`)
+	is.Equal(http.StatusBadRequest, w.Code)
+	is.Equal("{\"code\":\"InvalidArgument\",\"error\":\"request body is not a valid json\"}", w.Body.String())
+}
+
+func TestDecoding_XMLMap(t *testing.T) {
+	is := is.New(t)
+	for _, contentType := range []string{"application/xml", "text/xml; charset=utf-8", "application/atom+xml"} {
+		w := doTestRequest(decodingHelloHandler, "POST", "", http.Header{"Content-Type": {contentType}}, `<?xml version="1.0"?><user><name>John</name></user>`)
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("{\"msg\":\"hello John\"}", w.Body.String())
+	}
+	{
+		w := doTestRequest(func(req Request) (*Response, error) {
+			interests, err := req.GetStringList("interests", FromBody)
+			if err != nil {
+				return nil, err
+			}
+			return &Response{Data: strings.Join(interests, ",")}, nil
+		}, "POST", "", http.Header{"Content-Type": {"application/xml"}}, `<user><interests>Go</interests><interests>Tea</interests></user>`)
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("Go,Tea", w.Body.String())
+	}
+	{
+		w := doTestRequest(decodingHelloHandler, "POST", "", http.Header{"Content-Type": {"application/xml"}}, `{"name": "John"}`)
+		is.Equal(http.StatusBadRequest, w.Code)
+		is.Equal("{\"code\":\"InvalidArgument\",\"error\":\"request body is not a valid xml\"}", w.Body.String())
+	}
+}
+
+func TestDecoding_XMLBodyTo(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(func(req Request) (*Response, error) {
+		model := struct {
+			Name string `xml:"name"`
+			Age  int    `xml:"age"`
+		}{}
+		err := req.BodyTo(&model)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{
+			Data: map[string]any{
+				"name": model.Name,
+				"age":  model.Age,
+			},
+		}, nil
+	}, "POST", "", http.Header{"Content-Type": {"application/xml"}}, `<user><name>John</name><age>30</age></user>`)
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal("{\"age\":30,\"name\":\"John\"}", w.Body.String())
+}
+
+func TestDecoding_Registered(t *testing.T) {
+	is := is.New(t)
+	defer delete(decoders, "text/x-test-lines")
+	// "key=value" lines
+	RegisterDecoder("text/x-test-lines", func(body []byte, model any) error {
+		mapPtr, ok := model.(*map[string]any)
+		if !ok {
+			return NewError(Unimplemented, "", nil)
+		}
+		for _, line := range strings.Split(string(body), "\n") {
+			parts := strings.SplitN(line, "=", 2)
+			if len(parts) == 2 {
+				(*mapPtr)[parts[0]] = parts[1]
+			}
+		}
+		return nil
+	})
+	w := doTestRequest(decodingHelloHandler, "POST", "", http.Header{"Content-Type": {"text/x-test-lines"}}, "name=John\nage=30")
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal("{\"msg\":\"hello John\"}", w.Body.String())
+}
+
+func TestDecoding_Unsupported(t *testing.T) {
+	is := is.New(t)
+	{
+		w := doTestRequest(decodingHelloHandler, "POST", "", http.Header{"Content-Type": {"application/x-unknown"}}, `name John`)
+		is.Equal(http.StatusUnsupportedMediaType, w.Code)
+		is.Equal("{\"code\":\"UnsupportedMediaType\",\"error\":\"unsupported content type\"}", w.Body.String())
+	}
+	{
+		w := doTestRequest(func(req Request) (*Response, error) {
+			model := map[string]any{}
+			err := req.BodyTo(&model)
+			if err != nil {
+				return nil, err
+			}
+			return &Response{Data: model}, nil
+		}, "POST", "", http.Header{"Content-Type": {"application/x-unknown"}}, `name John`)
+		is.Equal(http.StatusUnsupportedMediaType, w.Code)
+	}
+	{
+		// invalid Content-Type in POST is rejected by r.ParseForm, so we use DELETE
+		r, err := http.NewRequest("DELETE", "", strings.NewReader(`name John`))
+		is.NotErr(err)
+		r.Header.Set("Content-Type", "no/valid/type")
+		w := httptest.NewRecorder()
+		TranslateHandler(decodingHelloHandler)(w, r)
+		is.Equal(http.StatusUnsupportedMediaType, w.Code)
+		is.Equal("{\"code\":\"UnsupportedMediaType\",\"error\":\"invalid content type\"}", w.Body.String())
+	}
+}
+
+func TestDecoding_Form(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(decodingHelloHandler, "POST", "", http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}, `name=John`)
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal("{\"msg\":\"hello John\"}", w.Body.String())
+}
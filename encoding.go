@@ -0,0 +1,241 @@
+package ripo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const jsonContentType = "application/json; charset=UTF-8"
+
+// Encoder: encodes Response.Data (or error body) into bytes of a specific media type
+type Encoder func(data any) ([]byte, error)
+
+type encoderEntry struct {
+	mediaType   string
+	contentType string
+	encode      Encoder
+}
+
+// registered encoders, in order of server preference
+// json is the only built-in encoder, so it wins wildcards and ties
+var encoders = []*encoderEntry{
+	{
+		mediaType:   "application/json",
+		contentType: jsonContentType,
+		encode:      encodeJSON,
+	},
+}
+
+// RegisterEncoder: add or replace the encoder for given media type, for example
+// RegisterEncoder("application/xml", xml.Marshal) or RegisterEncoder("text/csv", EncodeCSV)
+// New media types have lower priority than existing ones when client accepts both equally
+func RegisterEncoder(mediaType string, encoder Encoder) {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "" || encoder == nil {
+		panic("RegisterEncoder: empty media type or nil encoder")
+	}
+	for _, entry := range encoders {
+		if entry.mediaType == mediaType {
+			entry.encode = encoder
+			return
+		}
+	}
+	encoders = append(encoders, &encoderEntry{
+		mediaType:   mediaType,
+		contentType: mediaType,
+		encode:      encoder,
+	})
+}
+
+func findEncoder(mediaType string) *encoderEntry {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, entry := range encoders {
+		if entry.mediaType == mediaType {
+			return entry
+		}
+	}
+	return nil
+}
+
+func encodeJSON(data any) ([]byte, error) {
+	if data == nil {
+		data = map[string]any{}
+	}
+	return json.Marshal(data)
+}
+
+// EncodeCSV: encodes [][]string or [][]any as csv, to be registered with RegisterEncoder
+func EncodeCSV(data any) ([]byte, error) {
+	var records [][]string
+	switch dataTyped := data.(type) {
+	case [][]string:
+		records = dataTyped
+	case [][]any:
+		records = make([][]string, len(dataTyped))
+		for index, row := range dataTyped {
+			record := make([]string, len(row))
+			for col, value := range row {
+				record[col] = fmt.Sprint(value)
+			}
+			records[index] = record
+		}
+	default:
+		return nil, fmt.Errorf("csv: unsupported data type %T", data)
+	}
+	buf := bytes.NewBuffer(nil)
+	writer := csv.NewWriter(buf)
+	err := writer.WriteAll(records)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type acceptRange struct {
+	mediaType string // type/subtype, may contain "*"
+	q         float64
+}
+
+func (a acceptRange) specificity() int {
+	switch {
+	case a.mediaType == "*/*", a.mediaType == "*":
+		return 0
+	case strings.HasSuffix(a.mediaType, "/*"):
+		return 1
+	}
+	return 2
+}
+
+func (a acceptRange) matches(mediaType string) bool {
+	switch a.specificity() {
+	case 0:
+		return true
+	case 1:
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(a.mediaType, "*"))
+	}
+	return a.mediaType == mediaType
+}
+
+// parseAccept: parses Accept-like header values (Accept, Accept-Encoding) with q-values
+func parseAccept(header string) []acceptRange {
+	ranges := []acceptRange{}
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaType == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			qValue, err := strconv.ParseFloat(param[2:], 64)
+			if err == nil {
+				q = qValue
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+	// most specific ranges first, so they take precedence in qualityOf
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+	return ranges
+}
+
+func qualityOf(ranges []acceptRange, mediaType string) float64 {
+	for _, r := range ranges {
+		if r.matches(mediaType) {
+			return r.q
+		}
+	}
+	return 0
+}
+
+// negotiateEncoders: returns the encoders acceptable by Accept header, best match first
+// (server preference for equal q-values), or nil if none is acceptable
+func negotiateEncoders(accept string) []*encoderEntry {
+	if strings.TrimSpace(accept) == "" {
+		return encoders
+	}
+	ranges := parseAccept(accept)
+	acceptable := []*encoderEntry{}
+	qs := map[*encoderEntry]float64{}
+	for _, entry := range encoders {
+		q := qualityOf(ranges, entry.mediaType)
+		if q > 0 {
+			acceptable = append(acceptable, entry)
+			qs[entry] = q
+		}
+	}
+	if len(acceptable) == 0 {
+		return nil
+	}
+	sort.SliceStable(acceptable, func(i, j int) bool {
+		return qs[acceptable[i]] > qs[acceptable[j]]
+	})
+	return acceptable
+}
+
+// negotiateEncoder: returns the encoder matching Accept header best, or nil if none is acceptable
+func negotiateEncoder(accept string) *encoderEntry {
+	acceptable := negotiateEncoders(accept)
+	if len(acceptable) == 0 {
+		return nil
+	}
+	return acceptable[0]
+}
+
+func notAcceptableError(accept string) RPCError {
+	mediaTypes := make([]string, len(encoders))
+	for index, entry := range encoders {
+		mediaTypes[index] = entry.mediaType
+	}
+	return NewError(
+		NotAcceptable,
+		"none of the accepted media types can be produced",
+		nil,
+	).Add("accept", accept).Add("available", mediaTypes)
+}
+
+type errorBody struct {
+	XMLName xml.Name       `json:"-" xml:"error"`
+	Code    string         `json:"code" xml:"code"`
+	Error   string         `json:"error" xml:"message"`
+	Details map[string]any `json:"details,omitempty" xml:"-"`
+
+	RequestID string `json:"requestId,omitempty" xml:"requestId,omitempty"`
+}
+
+func newErrorBody(rpcErr RPCError, requestID string) *errorBody {
+	body := &errorBody{
+		Code:      rpcErr.Code().String(),
+		Error:     rpcErr.Error(),
+		RequestID: requestID,
+	}
+	if len(rpcErr.PublicDetails()) > 0 {
+		body.Details = rpcErr.PublicDetails()
+	}
+	return body
+}
+
+// encodeErrorBody: encodes error body with the negotiated encoder, falls back to json
+func encodeErrorBody(accept string, body *errorBody) (string, []byte) {
+	entry := negotiateEncoder(accept)
+	if entry != nil {
+		bodyBytes, err := entry.encode(body)
+		if err == nil {
+			return entry.contentType, bodyBytes
+		}
+	}
+	bodyBytes, _ := encodeJSON(body)
+	return jsonContentType, bodyBytes
+}
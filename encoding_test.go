@@ -0,0 +1,222 @@
+package ripo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+type encodingTestData struct {
+	RefNo string `json:"refNo" xml:"refNo"`
+}
+
+// registerTestEncoders: registers xml and csv encoders, returned function restores encoders
+func registerTestEncoders() func() {
+	saved := encoders
+	encoders = append([]*encoderEntry{}, encoders...)
+	RegisterEncoder("application/xml", xml.Marshal)
+	RegisterEncoder("text/csv", EncodeCSV)
+	return func() {
+		encoders = saved
+	}
+}
+
+func encodingTestHandler(req Request) (*Response, error) {
+	return &Response{
+		Data: &encodingTestData{RefNo: "1234"},
+	}, nil
+}
+
+func TestEncoding_DefaultJSON(t *testing.T) {
+	is := is.New(t)
+	for _, accept := range []string{"", "*/*", "application/*", "application/json"} {
+		w := doTestRequest(encodingTestHandler, "GET", "", http.Header{"Accept": {accept}}, "")
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("application/json; charset=UTF-8", w.Header().Get("Content-Type"))
+		is.Equal("{\"refNo\":\"1234\"}", w.Body.String())
+		is.Equal("Accept", w.Header().Get("Vary"))
+	}
+	{
+		// xml is not a built-in encoder
+		w := doTestRequest(encodingTestHandler, "GET", "", http.Header{"Accept": {"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"}}, "")
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("application/json; charset=UTF-8", w.Header().Get("Content-Type"))
+		is.Equal("Accept", w.Header().Get("Vary"))
+	}
+	{
+		w := doTestRequest(encodingTestHandler, "GET", "", http.Header{"Accept": {"application/xml"}}, "")
+		is.Equal(http.StatusNotAcceptable, w.Code)
+		is.Equal("Accept", w.Header().Get("Vary"))
+	}
+}
+
+func TestEncoding_XML(t *testing.T) {
+	is := is.New(t)
+	defer registerTestEncoders()()
+	w := doTestRequest(encodingTestHandler, "GET", "", http.Header{"Accept": {"application/xml"}}, "")
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal("application/xml", w.Header().Get("Content-Type"))
+	is.Equal("<encodingTestData><refNo>1234</refNo></encodingTestData>", w.Body.String())
+}
+
+func TestEncoding_QValues(t *testing.T) {
+	is := is.New(t)
+	defer registerTestEncoders()()
+	{
+		w := doTestRequest(encodingTestHandler, "GET", "", http.Header{"Accept": {"application/json;q=0.5, application/xml;q=0.9"}}, "")
+		is.Equal("application/xml", w.Header().Get("Content-Type"))
+	}
+	{
+		w := doTestRequest(encodingTestHandler, "GET", "", http.Header{"Accept": {"application/xml;q=0.5, */*;q=0.8"}}, "")
+		is.Equal("application/json; charset=UTF-8", w.Header().Get("Content-Type"))
+	}
+	{
+		// json wins wildcards
+		w := doTestRequest(encodingTestHandler, "GET", "", http.Header{"Accept": {"application/*"}}, "")
+		is.Equal("application/json; charset=UTF-8", w.Header().Get("Content-Type"))
+	}
+	{
+		w := doTestRequest(encodingTestHandler, "GET", "", http.Header{"Accept": {"application/json;q=0, application/*"}}, "")
+		is.Equal("application/xml", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestEncoding_Registered(t *testing.T) {
+	is := is.New(t)
+	defer func(saved []*encoderEntry) {
+		encoders = saved
+	}(encoders)
+	RegisterEncoder("application/x-test-upper", func(data any) ([]byte, error) {
+		return []byte(strings.ToUpper(fmt.Sprintf("%v", data))), nil
+	})
+	w := doTestRequest(func(req Request) (*Response, error) {
+		return &Response{Data: "hello"}, nil
+	}, "GET", "", http.Header{"Accept": {"application/x-test-upper"}}, "")
+	// string data is written as is
+	is.Equal("hello", w.Body.String())
+
+	w = doTestRequest(func(req Request) (*Response, error) {
+		return &Response{Data: []string{"hello"}}, nil
+	}, "GET", "", http.Header{"Accept": {"application/x-test-upper"}}, "")
+	is.Equal("application/x-test-upper", w.Header().Get("Content-Type"))
+	is.Equal("[HELLO]", w.Body.String())
+}
+
+func TestEncoding_CSV(t *testing.T) {
+	is := is.New(t)
+	defer registerTestEncoders()()
+	w := doTestRequest(func(req Request) (*Response, error) {
+		return &Response{Data: [][]any{
+			{"name", "age"},
+			{"John", 30},
+		}}, nil
+	}, "GET", "", http.Header{"Accept": {"text/csv"}}, "")
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal("text/csv", w.Header().Get("Content-Type"))
+	is.Equal("name,age\nJohn,30\n", w.Body.String())
+}
+
+func TestEncoding_Override(t *testing.T) {
+	is := is.New(t)
+	defer registerTestEncoders()()
+	{
+		w := doTestRequest(func(req Request) (*Response, error) {
+			return &Response{
+				Data:        &encodingTestData{RefNo: "1234"},
+				ContentType: "application/xml",
+			}, nil
+		}, "GET", "", nil, "")
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("application/xml", w.Header().Get("Content-Type"))
+		is.Equal("", w.Header().Get("Vary"))
+	}
+	{
+		w := doTestRequest(func(req Request) (*Response, error) {
+			return &Response{
+				Data:        &encodingTestData{RefNo: "1234"},
+				ContentType: "application/x-not-registered",
+			}, nil
+		}, "GET", "", nil, "")
+		is.Equal(http.StatusInternalServerError, w.Code)
+	}
+}
+
+func TestEncoding_NotAcceptable(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(func(req Request) (*Response, error) {
+		return &Response{
+			Data:   &encodingTestData{RefNo: "1234"},
+			Header: http.Header{"X-Test": []string{"1"}},
+		}, nil
+	}, "GET", "", http.Header{"Accept": {"image/png"}}, "")
+	is.Equal(http.StatusNotAcceptable, w.Code)
+	is.Equal("", w.Header().Get("X-Test"))
+	is.Equal("application/json; charset=UTF-8", w.Header().Get("Content-Type"))
+	is.Equal("{\"code\":\"NotAcceptable\",\"error\":\"none of the accepted media types can be produced\"}", w.Body.String())
+}
+
+func TestEncoding_Fallback(t *testing.T) {
+	is := is.New(t)
+	defer registerTestEncoders()()
+	mapHandler := func(req Request) (*Response, error) {
+		return &Response{
+			Data: map[string]any{"refNo": "1234"},
+		}, nil
+	}
+	{
+		// csv can not encode a map, next acceptable encoder is used
+		w := doTestRequest(mapHandler, "GET", "", http.Header{"Accept": {"text/csv, application/json;q=0.5"}}, "")
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("application/json; charset=UTF-8", w.Header().Get("Content-Type"))
+		is.Equal("{\"refNo\":\"1234\"}", w.Body.String())
+	}
+	{
+		w := doTestRequest(mapHandler, "GET", "", http.Header{"Accept": {"application/xml"}}, "")
+		is.Equal(http.StatusNotAcceptable, w.Code)
+		is.Equal("<error><code>NotAcceptable</code><message>none of the accepted media types can be produced</message></error>", w.Body.String())
+	}
+	{
+		w := doTestRequest(func(req Request) (*Response, error) {
+			return &Response{
+				Data: map[string]any{"ch": make(chan int)},
+			}, nil
+		}, "GET", "", http.Header{"Accept": {"application/json, application/xml"}}, "")
+		is.Equal(http.StatusInternalServerError, w.Code)
+		is.Equal("application/json; charset=UTF-8", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestEncoding_ErrorBody(t *testing.T) {
+	is := is.New(t)
+	defer registerTestEncoders()()
+	handler := func(req Request) (*Response, error) {
+		return nil, NewError(NotFound, "no such item", nil)
+	}
+	{
+		w := doTestRequest(handler, "GET", "", http.Header{"Accept": {"application/xml"}}, "")
+		is.Equal(http.StatusNotFound, w.Code)
+		is.Equal("application/xml", w.Header().Get("Content-Type"))
+		is.Equal("Accept", w.Header().Get("Vary"))
+		is.Equal("<error><code>NotFound</code><message>no such item</message></error>", w.Body.String())
+	}
+	{
+		// csv can not encode error body, falls back to json
+		w := doTestRequest(handler, "GET", "", http.Header{"Accept": {"text/csv"}}, "")
+		is.Equal(http.StatusNotFound, w.Code)
+		is.Equal("application/json; charset=UTF-8", w.Header().Get("Content-Type"))
+		is.Equal("{\"code\":\"NotFound\",\"error\":\"no such item\"}", w.Body.String())
+	}
+}
+
+func TestParseAccept(t *testing.T) {
+	is := is.New(t)
+	ranges := parseAccept("text/*;q=0.3, text/html;q=0.7, text/html;level=1, */*;q=0.5")
+	is.Equal(0.7, qualityOf(ranges, "text/html"))
+	is.Equal(0.3, qualityOf(ranges, "text/plain"))
+	is.Equal(0.5, qualityOf(ranges, "image/jpeg"))
+	is.Equal(0.0, qualityOf(parseAccept("text/plain"), "application/json"))
+}
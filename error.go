@@ -21,6 +21,8 @@ func NewError(code Code, publicMsg string, causeErr error) RPCError {
 		publicMsg: publicMsg,
 		traceback: &tracebackImp{callers: pc[:n]},
 		details:   map[string]any{},
+
+		publicDetails: map[string]any{},
 	}
 }
 
@@ -34,8 +36,10 @@ type RPCError interface {
 	Unwrap() error                          // not shown to user
 	Traceback(handlerName string) Traceback // not shown to user
 	Details() map[string]any        // not shown to user
+	PublicDetails() map[string]any  // shown to user
 
 	Add(key string, value any) RPCError
+	AddPublic(key string, value any) RPCError
 }
 
 type rpcErrorImp struct {
@@ -45,6 +49,8 @@ type rpcErrorImp struct {
 	cause     error                  // not shown to user
 	traceback *tracebackImp          // not shown to user
 	details   map[string]any // not shown to user
+
+	publicDetails map[string]any // shown to user
 }
 
 func (e *rpcErrorImp) Error() string {
@@ -60,10 +66,12 @@ func (e *rpcErrorImp) Code() Code {
 
 func (e *rpcErrorImp) GrpcCode() uint32 {
 	switch e.code {
-	case MissingArgument:
+	case MissingArgument, NotAcceptable, UnsupportedMediaType:
 		return uint32(InvalidArgument)
 	case ResourceLocked:
 		return uint32(Aborted)
+	case PayloadTooLarge, TooManyRequests:
+		return uint32(ResourceExhausted)
 	}
 	return uint32(e.code)
 }
@@ -95,3 +103,17 @@ func (e *rpcErrorImp) Add(key string, value any) RPCError {
 	}
 	return e
 }
+
+func (e *rpcErrorImp) PublicDetails() map[string]any {
+	return e.publicDetails
+}
+
+// AddPublic: add a detail that is exposed to client in error response
+// unlike Add, which is only used for logging
+func (e *rpcErrorImp) AddPublic(key string, value any) RPCError {
+	_, hasKey := e.publicDetails[key]
+	if !hasKey {
+		e.publicDetails[key] = value
+	}
+	return e
+}
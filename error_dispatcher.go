@@ -1,35 +1,36 @@
 package ripo
 
 import (
-	"fmt"
-	"log"
-	"strings"
+	"log/slog"
 )
 
-var errorDispatcher = func(request ExtendedRequest, rpcErr RPCError) {
-	parts := []string{
-		fmt.Sprintf("Code=%v", rpcErr.Code()),
-		fmt.Sprintf("Message=%#v", rpcErr.Message()),
+var errorDispatcher = defaultErrorDispatcher
+
+// defaultErrorDispatcher: logs the error with logger of request
+func defaultErrorDispatcher(request ExtendedRequest, rpcErr RPCError) {
+	attrs := []any{
+		slog.String("code", rpcErr.Code().String()),
+		slog.String("message", rpcErr.Message()),
 	}
 	if rpcErr.Cause() != nil {
-		parts = append(parts, fmt.Sprintf("Cause=%#v", rpcErr.Cause().Error()))
+		attrs = append(attrs, slog.String("cause", rpcErr.Cause().Error()))
 	}
 	if len(rpcErr.Details()) > 0 {
-		parts = append(parts, fmt.Sprintf("Details=%#v", rpcErr.Details()))
+		attrs = append(attrs, slog.Any("details", rpcErr.Details()))
 	}
-	tbLines := []string{
-		"Traceback:",
+	if len(rpcErr.PublicDetails()) > 0 {
+		attrs = append(attrs, slog.Any("publicDetails", rpcErr.PublicDetails()))
 	}
+	frames := []logFrame{}
 	for _, record := range rpcErr.Traceback(request.HandlerName()).Records() {
-		tbLines = append(tbLines, fmt.Sprintf(
-			"\tFile %v, Func %v, Line %v",
-			record.File(),
-			record.FunctionLocal(),
-			record.Line(),
-		))
+		frames = append(frames, logFrame{
+			File: record.File(),
+			Func: record.FunctionLocal(),
+			Line: record.Line(),
+		})
 	}
-	parts = append(parts, strings.Join(tbLines, "\n"))
-	log.Printf("RPCError: %v, \n", strings.Join(parts, ", "))
+	attrs = append(attrs, slog.Any("traceback", frames))
+	request.Logger().Error("RPCError", attrs...)
 }
 
 func SetErrorDispatcher(dispatcher func(request ExtendedRequest, rpcErr RPCError)) {
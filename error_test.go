@@ -125,3 +125,17 @@ func Test_tracebackImp_empty(t *testing.T) {
 	is.Equal(0, len(tb.Callers()))
 	is.Equal(0, len(tb.Records()))
 }
+
+func TestErrorPublicDetails(t *testing.T) {
+	is := is.New(t)
+	r, err := http.NewRequest("GET", "/", nil)
+	is.NotErr(err)
+	w := httptest.NewRecorder()
+	TranslateHandler(func(req Request) (*Response, error) {
+		return nil, NewError(
+			InvalidArgument, "invalid 'age'", nil,
+		).Add("secret", 1).AddPublic("min", 18).AddPublic("min", 20)
+	})(w, r)
+	is.Equal(http.StatusBadRequest, w.Code)
+	is.Equal(`{"code":"InvalidArgument","error":"invalid 'age'","details":{"min":18}}`, w.Body.String())
+}
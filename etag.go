@@ -0,0 +1,123 @@
+package ripo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// autoETag: compute ETag of response body for GET/HEAD requests, globally
+var autoETag = false
+
+// SetAutoETag: enable or disable computing a strong ETag from encoded response body
+// (for GET and HEAD requests) globally, can be overridden per handler with AutoETag option
+// If-None-Match is checked against ETag header (set automatically or by handler)
+// and 304 Not Modified is sent if it matches
+func SetAutoETag(enabled bool) {
+	autoETag = enabled
+}
+
+// ComputeETag: returns a strong ETag (with quotes) for given bytes
+func ComputeETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// ETagOf: returns ETag of data encoded as json, which is the same as automatic ETag of
+// a response with this data, if client accepts json
+func ETagOf(data any) (string, error) {
+	body, err := encodeJSON(data)
+	if err != nil {
+		return "", NewError(Internal, "", err)
+	}
+	return ComputeETag(body), nil
+}
+
+// CheckIfMatch: returns FailedPrecondition error if request has If-Match header
+// that does not match currentETag, to be used for optimistic concurrency in writes
+// pass empty currentETag if resource does not exist
+func CheckIfMatch(req Request, currentETag string) error {
+	ifMatch := req.Header("If-Match")
+	if ifMatch == "" {
+		return nil
+	}
+	if etagListMatches(ifMatch, currentETag, true) {
+		return nil
+	}
+	return NewError(
+		FailedPrecondition,
+		"resource has been modified",
+		nil,
+	).Add("ifMatch", ifMatch).Add("currentETag", currentETag)
+}
+
+// etagListMatches: reports whether etag matches any of the comma-separated list
+// strong comparison is used for If-Match, and weak comparison for If-None-Match
+func etagListMatches(list string, etag string, strong bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	if strong && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if strong {
+			// ETag of a compressed response has encoding suffix, but it's the same resource
+			if item == etag || etagWithoutEncoding(item) == etag {
+				return true
+			}
+			continue
+		}
+		if etagWithoutEncoding(strings.TrimPrefix(item, "W/")) == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// etagWithoutEncoding: removes content encoding suffix added by etagWithEncoding
+func etagWithoutEncoding(etag string) string {
+	if !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	for _, entry := range compressors {
+		suffix := "-" + entry.encoding + `"`
+		if strings.HasSuffix(etag, suffix) {
+			return strings.TrimSuffix(etag, suffix) + `"`
+		}
+	}
+	return etag
+}
+
+// etagWithEncoding: strong ETag must differ for each content encoding of the same resource
+func etagWithEncoding(etag string, encoding string) string {
+	if !strings.HasSuffix(etag, `"`) || strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// checkNotModified: sets ETag header if enabled, and reports whether If-None-Match matches it
+func checkNotModified(wh http.Header, r *http.Request, status int, body []byte, auto bool) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if status != http.StatusOK {
+		return false
+	}
+	etag := wh.Get("Etag")
+	if etag == "" && auto {
+		etag = ComputeETag(body)
+		wh.Set("Etag", etag)
+	}
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch == "" {
+		return false
+	}
+	return etagListMatches(ifNoneMatch, etag, false)
+}
@@ -0,0 +1,210 @@
+package ripo
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+type etagTestItem struct {
+	ID      string `json:"id"`
+	Version int    `json:"version"`
+}
+
+var etagTestCurrent = &etagTestItem{ID: "1", Version: 3}
+
+func etagGetHandler(req Request) (*Response, error) {
+	return &Response{Data: etagTestCurrent}, nil
+}
+
+func etagPutHandler(req Request) (*Response, error) {
+	currentETag, err := ETagOf(etagTestCurrent)
+	if err != nil {
+		return nil, err
+	}
+	err = CheckIfMatch(req, currentETag)
+	if err != nil {
+		return nil, err
+	}
+	return NoContent(), nil
+}
+
+func TestETag_Auto(t *testing.T) {
+	is := is.New(t)
+	etag, err := ETagOf(etagTestCurrent)
+	is.NotErr(err)
+	is.Equal(34, len(etag))
+	is.Equal(ComputeETag([]byte(`{"id":"1","version":3}`)), etag)
+	{
+		w := doTestRequest(etagGetHandler, "GET", "/item", nil, "", AutoETag(true))
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal(etag, w.Header().Get("Etag"))
+		is.Equal(`{"id":"1","version":3}`, w.Body.String())
+	}
+	{
+		w := doTestRequest(etagGetHandler, "GET", "/item", http.Header{"If-None-Match": []string{`"other", ` + etag}}, "", AutoETag(true))
+		is.Equal(http.StatusNotModified, w.Code)
+		is.Equal(etag, w.Header().Get("Etag"))
+		is.Equal("", w.Header().Get("Content-Type"))
+		is.Equal("", w.Body.String())
+	}
+	{
+		w := doTestRequest(etagGetHandler, "GET", "/item", http.Header{"If-None-Match": []string{"W/" + etag}}, "", AutoETag(true))
+		is.Equal(http.StatusNotModified, w.Code)
+	}
+	{
+		w := doTestRequest(etagGetHandler, "GET", "/item", http.Header{"If-None-Match": []string{`"other"`}}, "", AutoETag(true))
+		is.Equal(http.StatusOK, w.Code)
+	}
+	{
+		// disabled by default
+		w := doTestRequest(etagGetHandler, "GET", "/item", http.Header{"If-None-Match": []string{etag}}, "")
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("", w.Header().Get("Etag"))
+	}
+	{
+		// only for GET and HEAD
+		w := doTestRequest(etagGetHandler, "POST", "/item", http.Header{"If-None-Match": []string{etag}}, "", AutoETag(true))
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("", w.Header().Get("Etag"))
+	}
+}
+
+func TestETag_Global(t *testing.T) {
+	is := is.New(t)
+	SetAutoETag(true)
+	defer SetAutoETag(false)
+	{
+		w := doTestRequest(etagGetHandler, "GET", "/item", http.Header{"If-None-Match": []string{"*"}}, "")
+		is.Equal(http.StatusNotModified, w.Code)
+	}
+	{
+		w := doTestRequest(etagGetHandler, "GET", "/item", nil, "", AutoETag(false))
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("", w.Header().Get("Etag"))
+	}
+}
+
+func TestETag_HandlerSet(t *testing.T) {
+	is := is.New(t)
+	handler := func(req Request) (*Response, error) {
+		return &Response{
+			Data:   etagTestCurrent,
+			Header: http.Header{"Etag": []string{`"v3"`}},
+		}, nil
+	}
+	w := doTestRequest(handler, "GET", "/item", http.Header{"If-None-Match": []string{`"v3"`}}, "")
+	is.Equal(http.StatusNotModified, w.Code)
+	is.Equal(`"v3"`, w.Header().Get("Etag"))
+}
+
+func TestETag_Compressed(t *testing.T) {
+	is := is.New(t)
+	handler := func(req Request) (*Response, error) {
+		return &Response{Data: strings.Repeat("a", 2000)}, nil
+	}
+	etag := ComputeETag([]byte(strings.Repeat("a", 2000)))
+	gzipETag := strings.TrimSuffix(etag, `"`) + `-gzip"`
+	{
+		w := doTestRequest(handler, "GET", "/item", http.Header{"Accept-Encoding": []string{"gzip"}}, "", AutoETag(true))
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("gzip", w.Header().Get("Content-Encoding"))
+		is.Equal(gzipETag, w.Header().Get("Etag"))
+	}
+	{
+		w := doTestRequest(handler, "GET", "/item", http.Header{
+			"Accept-Encoding": []string{"gzip"},
+			"If-None-Match":   []string{gzipETag},
+		}, "", AutoETag(true))
+		is.Equal(http.StatusNotModified, w.Code)
+	}
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	is := is.New(t)
+	etag, err := ETagOf(etagTestCurrent)
+	is.NotErr(err)
+	{
+		w := doTestRequest(etagPutHandler, "PUT", "/item", nil, "")
+		is.Equal(http.StatusNoContent, w.Code)
+	}
+	{
+		w := doTestRequest(etagPutHandler, "PUT", "/item", http.Header{"If-Match": []string{etag}}, "")
+		is.Equal(http.StatusNoContent, w.Code)
+	}
+	{
+		w := doTestRequest(etagPutHandler, "PUT", "/item", http.Header{"If-Match": []string{"*"}}, "")
+		is.Equal(http.StatusNoContent, w.Code)
+	}
+	{
+		w := doTestRequest(etagPutHandler, "PUT", "/item", http.Header{"If-Match": []string{`"old"`}}, "")
+		is.Equal(http.StatusPreconditionFailed, w.Code)
+		is.Equal("{\"code\":\"FailedPrecondition\",\"error\":\"resource has been modified\"}", w.Body.String())
+	}
+	{
+		// strong comparison
+		w := doTestRequest(etagPutHandler, "PUT", "/item", http.Header{"If-Match": []string{"W/" + etag}}, "")
+		is.Equal(http.StatusPreconditionFailed, w.Code)
+	}
+	{
+		r, _ := http.NewRequest("PUT", "/item", nil)
+		r.Header.Set("If-Match", "*")
+		err := CheckIfMatch(&requestImp{r: r}, "")
+		AssertError(t, err, FailedPrecondition, "resource has been modified")
+	}
+}
+
+func TestCheckIfMatch_Compressed(t *testing.T) {
+	is := is.New(t)
+	items := make([]*etagTestItem, 100)
+	for index := range items {
+		items[index] = &etagTestItem{ID: "1", Version: index}
+	}
+	getHandler := func(req Request) (*Response, error) {
+		return &Response{Data: items}, nil
+	}
+	putHandler := func(req Request) (*Response, error) {
+		currentETag, err := ETagOf(items)
+		if err != nil {
+			return nil, err
+		}
+		err = CheckIfMatch(req, currentETag)
+		if err != nil {
+			return nil, err
+		}
+		return NoContent(), nil
+	}
+	w := doTestRequest(getHandler, "GET", "/item", http.Header{"Accept-Encoding": []string{"gzip"}}, "", AutoETag(true))
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal("gzip", w.Header().Get("Content-Encoding"))
+	etag := w.Header().Get("Etag")
+	is.True(strings.HasSuffix(etag, `-gzip"`))
+	{
+		w := doTestRequest(putHandler, "PUT", "/item", http.Header{"If-Match": []string{etag}}, "")
+		is.Equal(http.StatusNoContent, w.Code)
+	}
+	{
+		// ETag set by handler
+		w := doTestRequest(func(req Request) (*Response, error) {
+			return &Response{
+				Data:   items,
+				Header: http.Header{"Etag": []string{`"v3"`}},
+			}, nil
+		}, "GET", "/item", http.Header{"Accept-Encoding": []string{"gzip"}}, "")
+		is.Equal(`"v3-gzip"`, w.Header().Get("Etag"))
+		w = doTestRequest(func(req Request) (*Response, error) {
+			err := CheckIfMatch(req, `"v3"`)
+			if err != nil {
+				return nil, err
+			}
+			return NoContent(), nil
+		}, "PUT", "/item", http.Header{"If-Match": []string{w.Header().Get("Etag")}}, "")
+		is.Equal(http.StatusNoContent, w.Code)
+	}
+	{
+		w := doTestRequest(putHandler, "PUT", "/item", http.Header{"If-Match": []string{`"old-gzip"`}}, "")
+		is.Equal(http.StatusPreconditionFailed, w.Code)
+	}
+}
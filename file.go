@@ -0,0 +1,105 @@
+package ripo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"time"
+)
+
+// File: can be used as Response.Data to serve a file
+// supports Range, If-Modified-Since, If-None-Match (with ETag in Response.Header) and similar headers
+type File struct {
+	Content io.ReadSeeker // closed after serving, if it's an io.Closer
+
+	// Name: base name is used for content type detection and Content-Disposition
+	Name string
+
+	// ModTime: used for Last-Modified and If-Modified-Since, ignored if zero
+	ModTime time.Time
+
+	// ContentType: detected from Name, or otherwise from content, if empty
+	ContentType string
+
+	// Attachment: set Content-Disposition to attachment, so browser downloads the file
+	Attachment bool
+}
+
+// FileResponse: response that serves the file
+func FileResponse(file *File) *Response {
+	return &Response{
+		Data: file,
+	}
+}
+
+// FSFile: opens the file in given file system
+// returns NotFound error if it does not exist or is a directory
+func FSFile(fsys fs.FS, name string) (*File, error) {
+	fsFile, err := fsys.Open(name)
+	if err != nil {
+		return nil, fsError(err, name)
+	}
+	stat, err := fsFile.Stat()
+	if err != nil {
+		fsFile.Close()
+		return nil, fsError(err, name)
+	}
+	if stat.IsDir() {
+		fsFile.Close()
+		return nil, NewError(NotFound, "file not found", nil).Add("name", name)
+	}
+	content, ok := fsFile.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(fsFile)
+		fsFile.Close()
+		if err != nil {
+			return nil, NewError(Internal, "", err).Add("name", name)
+		}
+		content = bytes.NewReader(data)
+	}
+	return &File{
+		Content: content,
+		Name:    path.Base(name),
+		ModTime: stat.ModTime(),
+	}, nil
+}
+
+func fsError(err error, name string) RPCError {
+	switch {
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrInvalid):
+		return NewError(NotFound, "file not found", err).Add("name", name)
+	case errors.Is(err, fs.ErrPermission):
+		return NewError(PermissionDenied, "", err).Add("name", name)
+	}
+	return NewError(Internal, "", err).Add("name", name)
+}
+
+func serveFile(w http.ResponseWriter, r *http.Request, file *File) error {
+	if file.Content == nil {
+		return NewError(Internal, "", fmt.Errorf("file %#v has nil Content", file.Name))
+	}
+	closer, ok := file.Content.(io.Closer)
+	if ok {
+		defer closer.Close()
+	}
+	wh := w.Header()
+	if file.ContentType != "" {
+		wh.Set("Content-Type", file.ContentType)
+	}
+	if file.Attachment {
+		disposition := "attachment"
+		if file.Name != "" {
+			disposition = mime.FormatMediaType("attachment", map[string]string{
+				"filename": path.Base(file.Name),
+			})
+		}
+		wh.Set("Content-Disposition", disposition)
+	}
+	http.ServeContent(w, r, path.Base(file.Name), file.ModTime, file.Content)
+	return nil
+}
@@ -0,0 +1,152 @@
+package ripo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ilius/is/v2"
+)
+
+var fileTestModTime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+var fileTestFS = fstest.MapFS{
+	"docs/readme.txt": &fstest.MapFile{
+		Data:    []byte("0123456789abcdef"),
+		ModTime: fileTestModTime,
+	},
+	"docs/noext": &fstest.MapFile{
+		Data:    []byte("<html><body>hi</body></html>"),
+		ModTime: fileTestModTime,
+	},
+}
+
+func fileTestHandler(req Request) (*Response, error) {
+	name, err := req.GetString("name", FromForm)
+	if err != nil {
+		return nil, err
+	}
+	file, err := FSFile(fileTestFS, *name)
+	if err != nil {
+		return nil, err
+	}
+	file.Attachment = req.Header("X-Download") != ""
+	res := FileResponse(file)
+	res.Header = http.Header{"Etag": []string{`"v1"`}}
+	return res, nil
+}
+
+func TestFile_Full(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(fileTestHandler, "GET", "/file?name=docs/readme.txt", nil, "")
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal("text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	is.Equal("16", w.Header().Get("Content-Length"))
+	is.Equal("bytes", w.Header().Get("Accept-Ranges"))
+	is.Equal(fileTestModTime.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+	is.Equal("", w.Header().Get("Content-Disposition"))
+	is.Equal("0123456789abcdef", w.Body.String())
+}
+
+func TestFile_Sniffing(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(fileTestHandler, "GET", "/file?name=docs/noext", nil, "")
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal("text/html; charset=utf-8", w.Header().Get("Content-Type"))
+}
+
+func TestFile_Attachment(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(fileTestHandler, "GET", "/file?name=docs/readme.txt", http.Header{"X-Download": []string{"1"}}, "")
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal("attachment; filename=readme.txt", w.Header().Get("Content-Disposition"))
+}
+
+func TestFile_Range(t *testing.T) {
+	is := is.New(t)
+	{
+		w := doTestRequest(fileTestHandler, "GET", "/file?name=docs/readme.txt", http.Header{"Range": []string{"bytes=2-5"}}, "")
+		is.Equal(http.StatusPartialContent, w.Code)
+		is.Equal("bytes 2-5/16", w.Header().Get("Content-Range"))
+		is.Equal("2345", w.Body.String())
+	}
+	{
+		w := doTestRequest(fileTestHandler, "GET", "/file?name=docs/readme.txt", http.Header{"Range": []string{"bytes=100-200"}}, "")
+		is.Equal(http.StatusRequestedRangeNotSatisfiable, w.Code)
+		is.Equal("bytes */16", w.Header().Get("Content-Range"))
+	}
+	{
+		// If-Range does not match, full content is sent
+		w := doTestRequest(fileTestHandler, "GET", "/file?name=docs/readme.txt", http.Header{
+			"Range":    []string{"bytes=2-5"},
+			"If-Range": []string{`"v0"`},
+		}, "")
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("0123456789abcdef", w.Body.String())
+	}
+}
+
+func TestFile_Conditional(t *testing.T) {
+	is := is.New(t)
+	{
+		w := doTestRequest(fileTestHandler, "GET", "/file?name=docs/readme.txt", http.Header{
+			"If-Modified-Since": []string{fileTestModTime.Format(http.TimeFormat)},
+		}, "")
+		is.Equal(http.StatusNotModified, w.Code)
+		is.Equal("", w.Body.String())
+	}
+	{
+		w := doTestRequest(fileTestHandler, "GET", "/file?name=docs/readme.txt", http.Header{
+			"If-Modified-Since": []string{fileTestModTime.Add(-time.Hour).Format(http.TimeFormat)},
+		}, "")
+		is.Equal(http.StatusOK, w.Code)
+	}
+	{
+		w := doTestRequest(fileTestHandler, "GET", "/file?name=docs/readme.txt", http.Header{"If-None-Match": []string{`"v1"`}}, "")
+		is.Equal(http.StatusNotModified, w.Code)
+		is.Equal(`"v1"`, w.Header().Get("Etag"))
+	}
+}
+
+func TestFile_NotFound(t *testing.T) {
+	is := is.New(t)
+	for _, name := range []string{"docs/missing.txt", "docs", "../etc/passwd"} {
+		w := doTestRequest(fileTestHandler, "GET", "/file?name="+name, nil, "")
+		is.Equal(http.StatusNotFound, w.Code)
+		is.Equal("{\"code\":\"NotFound\",\"error\":\"file not found\"}", w.Body.String())
+	}
+}
+
+func TestFile_Reader(t *testing.T) {
+	is := is.New(t)
+	r, err := http.NewRequest("GET", "/export", nil)
+	is.NotErr(err)
+	r.Header.Set("Range", "bytes=-3")
+	w := httptest.NewRecorder()
+	TranslateHandler(func(req Request) (*Response, error) {
+		return FileResponse(&File{
+			Content:     strings.NewReader("a,b\n1,2\n"),
+			Name:        "export.csv",
+			ContentType: "text/csv",
+			Attachment:  true,
+		}), nil
+	})(w, r)
+	is.Equal(http.StatusPartialContent, w.Code)
+	is.Equal("text/csv", w.Header().Get("Content-Type"))
+	is.Equal("attachment; filename=export.csv", w.Header().Get("Content-Disposition"))
+	is.Equal(",2\n", w.Body.String())
+}
+
+func TestFile_NilContent(t *testing.T) {
+	is := is.New(t)
+	r, err := http.NewRequest("GET", "/export", nil)
+	is.NotErr(err)
+	w := httptest.NewRecorder()
+	TranslateHandler(func(req Request) (*Response, error) {
+		return FileResponse(&File{Name: "export.csv"}), nil
+	})(w, r)
+	is.Equal(http.StatusInternalServerError, w.Code)
+}
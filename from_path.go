@@ -0,0 +1,73 @@
+package ripo
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// FromPath: parameter source for path parameters of Router, like "id" in "/users/{id}"
+var FromPath FromX = &fromPath{}
+
+type fromPath struct{}
+
+type pathParamsKey struct{}
+
+func withPathParams(ctx context.Context, params map[string]string) context.Context {
+	return context.WithValue(ctx, pathParamsKey{}, params)
+}
+
+// PathParams: returns path parameters of Router that are matched for this request context
+func PathParams(ctx context.Context) map[string]string {
+	params, _ := ctx.Value(pathParamsKey{}).(map[string]string)
+	return params
+}
+
+// valueLookupRequest: exposes values of another source (like path parameters or headers)
+// as form values, so parsing is the same as FromForm
+type valueLookupRequest struct {
+	ExtendedRequest
+	lookup func(key string) string
+}
+
+func (req *valueLookupRequest) GetFormValue(key string) string {
+	return req.lookup(key)
+}
+
+func newPathParamsRequest(req ExtendedRequest) *valueLookupRequest {
+	params := PathParams(req.Context())
+	return &valueLookupRequest{
+		ExtendedRequest: req,
+		lookup: func(key string) string {
+			return params[key]
+		},
+	}
+}
+
+func (f *fromPath) GetString(req ExtendedRequest, key string) (*string, error) {
+	return FromForm.GetString(newPathParamsRequest(req), key)
+}
+
+func (f *fromPath) GetStringList(req ExtendedRequest, key string) ([]string, error) {
+	return FromForm.GetStringList(newPathParamsRequest(req), key)
+}
+
+func (f *fromPath) GetInt(req ExtendedRequest, key string) (*int, error) {
+	return FromForm.GetInt(newPathParamsRequest(req), key)
+}
+
+func (f *fromPath) GetFloat(req ExtendedRequest, key string) (*float64, error) {
+	return FromForm.GetFloat(newPathParamsRequest(req), key)
+}
+
+func (f *fromPath) GetBool(req ExtendedRequest, key string) (*bool, error) {
+	return FromForm.GetBool(newPathParamsRequest(req), key)
+}
+
+func (f *fromPath) GetTime(req ExtendedRequest, key string) (*time.Time, error) {
+	return FromForm.GetTime(newPathParamsRequest(req), key)
+}
+
+func (f *fromPath) GetObject(req ExtendedRequest, key string, _type reflect.Type) (any, error) {
+	return nil, nil
+}
@@ -1,129 +1,281 @@
 package ripo
 
 import (
-	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"reflect"
-	"runtime"
 )
 
 type Handler func(req Request) (res *Response, err error)
 
-func callHandler(handler Handler, request Request) (res *Response, err error) {
-	defer func() {
-		panicMsg := recover()
-		if panicMsg != nil {
-			err = NewError(
-				Internal,
-				Internal.String(),
-				fmt.Errorf(
-					"panic in handler %v: %v",
-					getFunctionName(handler),
-					panicMsg,
-				),
-			)
+// recoverHandlerPanic: converts a panic in handler into Internal error in *err
+// must be deferred directly, so handler stays right above its caller in tracebacks
+func recoverHandlerPanic(request *requestImp, err *error) {
+	panicMsg := recover()
+	if panicMsg != nil {
+		*err = NewError(
+			Internal,
+			Internal.String(),
+			fmt.Errorf(
+				"panic in handler %v: %v",
+				request.handlerName,
+				panicMsg,
+			),
+		)
+	}
+}
+
+func callHandler(handler Handler, request *requestImp) (res *Response, err error) {
+	defer request.releaseLocks()
+	defer recoverHandlerPanic(request, &err)
+	return handler(request)
+}
+
+// startRequest: common setup of all handler types, observes the request (access log,
+// metrics and tracing), sets up request ID, and returns the request
+// returned function must be deferred, it finishes observing and closes the request body
+func startRequest(
+	w http.ResponseWriter,
+	r *http.Request,
+	config *handlerConfig,
+	handlerName string,
+) (http.ResponseWriter, *requestImp, func()) {
+	w, r, finishObserve := observeRequest(w, r, config, handlerName)
+	r, requestID := setupRequestID(w, r)
+	request := &requestImp{
+		r:           r,
+		handlerName: handlerName,
+		maxBodySize: config.getMaxBodySize(),
+		logger:      config.getLogger(),
+		requestID:   requestID,
+	}
+	return w, request, func() {
+		finishObserve()
+		if r.Body != nil {
+			r.Body.Close()
 		}
-	}()
-	res, err = handler(request)
-	return
+	}
 }
 
-func handleError(err error, handlerName string, w http.ResponseWriter, request ExtendedRequest) {
-	code := Unknown
-	errorMsg := "Unknown" // FIXME: "unknown"
+// applyLimits: applies rate and concurrency limits, before the body is read
+// returned function releases concurrency slots, and must be called after response is written
+func applyLimits(w http.ResponseWriter, request *requestImp, config *handlerConfig) (func(), error) {
+	err := applyRateLimits(w, request, config)
+	if err != nil {
+		return nil, err
+	}
+	return acquireConcurrency(w, request, config)
+}
+
+// asRPCError: converts err to RPCError, logging it if it's not one
+func asRPCError(err error, logger *slog.Logger) RPCError {
 	rpcErr, isRpcErr := err.(RPCError)
 	if isRpcErr {
-		code = rpcErr.Code()
-		errorMsg = rpcErr.Error() // FIXME: use a mapping or make it space-separated
-	} else {
-		log.Printf(
-			"myrpc.TranslateHandler: handler '%v' returned non-rpc error: %#v\n",
-			handlerName,
-			err,
-		)
-		rpcErr = NewError(
-			Unknown, "", err,
-		)
+		return rpcErr
 	}
-	status := HTTPStatusFromCode(code)
-	jsonByte, _ := json.Marshal(map[string]string{
-		"code":  code.String(),
-		"error": errorMsg,
-	})
-	http.Error(
-		w,
-		string(jsonByte),
-		status,
+	logger.Warn(
+		"handler returned non-rpc error",
+		slog.String("error", err.Error()),
+		slog.String("errorType", fmt.Sprintf("%T", err)),
 	)
+	return NewError(
+		Unknown, "", err,
+	)
+}
+
+// addRequestDetails: adds request ID and trace ID of request to error details
+func addRequestDetails(rpcErr RPCError, request ExtendedRequest) {
+	if request.RequestID() != "" {
+		rpcErr.Add("requestId", request.RequestID())
+	}
+	sc := SpanContextFromContext(request.Context())
+	if sc != nil {
+		rpcErr.Add("traceId", sc.TraceID)
+	}
+}
+
+func handleError(err error, w http.ResponseWriter, request ExtendedRequest) {
+	rpcErr := asRPCError(err, request.Logger())
+	writeError(w, request, rpcErr, HTTPStatusFromCode(rpcErr.Code()))
+}
+
+// writeError: writes error response with given status, and dispatches the error
+func writeError(w http.ResponseWriter, request ExtendedRequest, rpcErr RPCError, status int) {
+	addRequestDetails(rpcErr, request)
+	setObservedCode(w, rpcErr.Code())
+	contentType, bodyBytes := encodeErrorBody(request.Header("Accept"), newErrorBody(rpcErr, request.RequestID()))
+	wh := w.Header()
+	addVary(wh, "Accept")
+	wh.Set("Content-Type", contentType)
+	wh.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, writeErr := w.Write(bodyBytes)
+	if writeErr != nil {
+		request.Logger().Warn("error in writing error response", slog.String("error", writeErr.Error()))
+	}
 	errorDispatcher(request, rpcErr)
 }
 
-func TranslateHandler(handler Handler) http.HandlerFunc {
-	handlerFuncObj := runtime.FuncForPC(reflect.ValueOf(handler).Pointer())
-	handlerName := handlerFuncObj.Name()
+func TranslateHandler(handler Handler, options ...HandlerOption) http.HandlerFunc {
+	config := newHandlerConfig(options)
+	handlerName := config.getHandlerName(handler)
 	return func(w http.ResponseWriter, r *http.Request) {
-		defer func() {
-			if r != nil && r.Body != nil {
-				r.Body.Close()
-			}
-		}()
-		err := r.ParseForm()
+		w, request, finish := startRequest(w, r, config, handlerName)
+		defer finish()
+		release, err := applyLimits(w, request, config)
 		if err != nil {
-			http.Error(w, "error in parsing form", http.StatusBadRequest)
+			handleError(err, w, request)
 			return
 		}
-		request := &requestImp{
-			r:           r,
-			handlerName: handlerName,
+		defer release()
+		err = request.parseForm(w)
+		if err != nil {
+			if _, isRpcErr := err.(RPCError); isRpcErr {
+				handleError(err, w, request)
+				return
+			}
+			http.Error(w, "error in parsing form", http.StatusBadRequest)
+			return
 		}
 		res, err := callHandler(handler, request)
 		if res == nil && err == nil {
 			err = NewError(Internal, "", fmt.Errorf("handler %v returned nil response with nil error", handlerName))
 		}
 		if err != nil {
-			handleError(err, handlerName, w, request)
+			handleError(err, w, request)
 			return
 		}
-		wh := w.Header()
-		if res.Header != nil {
-			for key, values := range res.Header {
-				for _, value := range values {
-					wh.Add(key, value)
-				}
-			}
+		writeResponse(w, res, request, config)
+	}
+}
+
+// encodeResponseData: returns content type (empty if unknown) and body bytes of res.Data
+// or an error if the response can not be encoded in any media type accepted by client
+// if an acceptable encoder fails, the next acceptable one is tried, unless it's json
+func encodeResponseData(r *http.Request, res *Response, logger *slog.Logger) (string, []byte, error) {
+	switch dataTyped := res.Data.(type) {
+	case []byte:
+		return "", dataTyped, nil
+	case string:
+		return "", []byte(dataTyped), nil
+	}
+	accept := r.Header.Get("Accept")
+	var acceptable []*encoderEntry
+	if res.ContentType != "" {
+		encoder := findEncoder(res.ContentType)
+		if encoder == nil {
+			return "", nil, NewError(
+				Internal, "",
+				fmt.Errorf("no encoder registered for media type %#v", res.ContentType),
+			)
 		}
-		if res.RedirectPath != "" {
-			code := res.RedirectStatusCode
-			if code == 0 {
-				code = http.StatusSeeOther
-			}
-			http.Redirect(w, r, res.RedirectPath, code)
-			return
+		acceptable = []*encoderEntry{encoder}
+	} else {
+		acceptable = negotiateEncoders(accept)
+		if len(acceptable) == 0 {
+			return "", nil, notAcceptableError(accept)
 		}
-		var resBodyBytes []byte
-		data := res.Data
-		switch dataTyped := data.(type) {
-		case []byte:
-			resBodyBytes = dataTyped
-		case string:
-			resBodyBytes = []byte(dataTyped)
-		default:
-			if data == nil {
-				data = map[string]any{}
-			}
-			jsonBytes, err := json.Marshal(data)
-			if err != nil {
-				log.Println("error in json.Marshal(res.Data):", err)
-			} else {
-				w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-				resBodyBytes = jsonBytes
-			}
+	}
+	var lastErr error
+	for _, encoder := range acceptable {
+		bodyBytes, err := encoder.encode(res.Data)
+		if err == nil {
+			return encoder.contentType, bodyBytes, nil
+		}
+		logger.Warn(
+			"error in encoding response data",
+			slog.String("mediaType", encoder.mediaType),
+			slog.String("error", err.Error()),
+		)
+		lastErr = err
+		// json can encode anything that other encoders can, data is broken
+		if res.ContentType != "" || encoder.mediaType == "application/json" {
+			return "", nil, NewError(
+				Internal, "",
+				fmt.Errorf("error in encoding response data: %w", err),
+			)
+		}
+	}
+	rpcErr := notAcceptableError(accept)
+	rpcErr.Add("encodeError", lastErr.Error())
+	return "", nil, rpcErr
+}
+
+func setResponseHeaders(w http.ResponseWriter, res *Response) {
+	wh := w.Header()
+	for key, values := range res.Header {
+		for _, value := range values {
+			wh.Add(key, value)
+		}
+	}
+	for _, cookie := range res.Cookies {
+		http.SetCookie(w, cookie)
+	}
+}
+
+func writeResponse(w http.ResponseWriter, res *Response, request *requestImp, config *handlerConfig) {
+	r := request.r
+	file, isFile := res.Data.(*File)
+	if isFile && res.RedirectPath == "" {
+		setResponseHeaders(w, res)
+		err := serveFile(w, request.r, file)
+		if err != nil {
+			handleError(err, w, request)
 		}
-		_, err = w.Write(resBodyBytes)
+		return
+	}
+	if res.RedirectPath == "" && res.hasBody() && isStreamData(res.Data) {
+		setResponseHeaders(w, res)
+		writeStream(w, res, request)
+		return
+	}
+	var contentType string
+	var resBodyBytes []byte
+	if res.RedirectPath == "" && res.hasBody() {
+		var err error
+		contentType, resBodyBytes, err = encodeResponseData(r, res, request.Logger())
 		if err != nil {
-			log.Println("error in w.Write(resBodyBytes):", err)
+			handleError(err, w, request)
+			return
 		}
 	}
+	wh := w.Header()
+	setResponseHeaders(w, res)
+	if contentType != "" && res.ContentType == "" {
+		// media type is negotiated
+		addVary(wh, "Accept")
+	}
+	if res.RedirectPath != "" {
+		code := res.RedirectStatusCode
+		if code == 0 {
+			code = http.StatusSeeOther
+		}
+		http.Redirect(w, r, res.RedirectPath, code)
+		return
+	}
+	status := res.statusCode()
+	if !res.hasBody() {
+		w.WriteHeader(status)
+		return
+	}
+	if contentType != "" {
+		wh.Set("Content-Type", contentType)
+	}
+	if wh.Get("Content-Type") == "" {
+		wh.Set("Content-Type", http.DetectContentType(resBodyBytes))
+	}
+	if checkNotModified(wh, r, status, resBodyBytes, config.getAutoETag()) {
+		wh.Del("Content-Type")
+		wh.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if !config.disableCompression && !res.DisableCompression {
+		resBodyBytes = compressResponseBody(wh, r.Header.Get("Accept-Encoding"), resBodyBytes, request.Logger())
+	}
+	w.WriteHeader(status)
+	_, err := w.Write(resBodyBytes)
+	if err != nil {
+		request.Logger().Warn("error in writing response", slog.String("error", err.Error()))
+	}
 }
@@ -234,10 +234,10 @@ func TestHandler_ResData_HugeNum(t *testing.T) {
 	}
 	w := httptest.NewRecorder()
 	handlerFunc(w, r)
-	is.Equal(http.StatusOK, w.Code)
-	is.Equal("text/plain; charset=utf-8", w.Header().Get("Content-Type")) // not json
+	is.Equal(http.StatusInternalServerError, w.Code)
+	is.Equal("application/json; charset=UTF-8", w.Header().Get("Content-Type"))
 	body := strings.TrimSpace(w.Body.String())
-	is.Equal("", body)
+	is.Equal("{\"code\":\"Internal\",\"error\":\"Internal\"}", body)
 }
 
 func TestHandler_ResRedirectPath_DefaultCode(t *testing.T) {
@@ -3,6 +3,8 @@ package ripo
 import (
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 )
 
 func init() {
@@ -14,4 +16,41 @@ func init() {
 	rpcErr := NewError(Unavailable, "", fmt.Errorf("boo")).Add("foo", "bar")
 	errorDispatcher(request, rpcErr)
 	SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {})
+	// tests compare whole error bodies, that would include generated request ID
+	SetRequestIDGenerator(nil)
+}
+
+// doTestRequest: serves a request with given method, url, header and body
+// handler is a Handler or SSEHandler, translated with options, or an http.Handler
+func doTestRequest(
+	handler any,
+	method string,
+	url string,
+	header http.Header,
+	body string,
+	options ...HandlerOption,
+) *httptest.ResponseRecorder {
+	r, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		panic(err)
+	}
+	for key, values := range header {
+		r.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	switch handlerTyped := handler.(type) {
+	case Handler:
+		TranslateHandler(handlerTyped, options...)(w, r)
+	case func(Request) (*Response, error):
+		TranslateHandler(handlerTyped, options...)(w, r)
+	case SSEHandler:
+		TranslateSSEHandler(handlerTyped, options...)(w, r)
+	case func(Request, EventStream) error:
+		TranslateSSEHandler(handlerTyped, options...)(w, r)
+	case http.Handler:
+		handlerTyped.ServeHTTP(w, r)
+	default:
+		panic(fmt.Sprintf("doTestRequest: unsupported handler type %T", handler))
+	}
+	return w
 }
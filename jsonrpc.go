@@ -0,0 +1,275 @@
+package ripo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	JSONRPCParseError     = -32700
+	JSONRPCInvalidRequest = -32600
+	JSONRPCMethodNotFound = -32601
+	JSONRPCInvalidParams  = -32602
+	JSONRPCInternalError  = -32603
+
+	// JSONRPCServerError: other codes are mapped to JSONRPCServerError - int(code)
+	// which is in the range reserved for implementation-defined server errors
+	JSONRPCServerError = -32000
+)
+
+type jsonrpcErrorData struct {
+	Code    string         `json:"code"`
+	Details map[string]any `json:"details,omitempty"`
+
+	RequestID string `json:"requestId,omitempty"`
+}
+
+type jsonrpcError struct {
+	Code    int               `json:"code"`
+	Message string            `json:"message"`
+	Data    *jsonrpcErrorData `json:"data,omitempty"`
+}
+
+type jsonrpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonrpcError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// JSONRPCErrorCode: returns JSON-RPC 2.0 error code for given code
+func JSONRPCErrorCode(code Code) int {
+	switch code {
+	case InvalidArgument, MissingArgument:
+		return JSONRPCInvalidParams
+	case Internal, Unknown:
+		return JSONRPCInternalError
+	}
+	return JSONRPCServerError - int(code)
+}
+
+func newJSONRPCError(code int, message string) *jsonrpcError {
+	return &jsonrpcError{
+		Code:    code,
+		Message: message,
+	}
+}
+
+func jsonrpcErrorFromRPCError(rpcErr RPCError, requestID string) *jsonrpcError {
+	data := &jsonrpcErrorData{
+		Code:      rpcErr.Code().String(),
+		RequestID: requestID,
+	}
+	if len(rpcErr.PublicDetails()) > 0 {
+		data.Details = rpcErr.PublicDetails()
+	}
+	return &jsonrpcError{
+		Code:    JSONRPCErrorCode(rpcErr.Code()),
+		Message: rpcErr.Error(),
+		Data:    data,
+	}
+}
+
+// JSONRPCHandler: serves JSON-RPC 2.0 requests (including batches and notifications) over HTTP POST
+// params of each call must be an object (named params), and are exposed as request body, so
+// req.Get* methods with FromBody source and req.BodyTo work as usual
+// Data of the response returned by handler is encoded as json in result
+// RPCError returned by handler is converted to error object with code given by JSONRPCErrorCode,
+// and public details (added by AddPublic) in data
+func JSONRPCHandler(methods map[string]Handler, options ...HandlerOption) http.HandlerFunc {
+	config := newHandlerConfig(options)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w, request, finish := startRequest(w, r, config, "JSONRPCHandler")
+		defer finish()
+		r = request.r
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(
+				w, request,
+				NewError(Unimplemented, "JSON-RPC requests must use POST method", nil).Add("method", r.Method),
+				http.StatusMethodNotAllowed,
+			)
+			return
+		}
+		release, err := applyLimits(w, request, config)
+		if err != nil {
+			handleError(err, w, request)
+			return
+		}
+		defer release()
+		body, err := request.Body()
+		if err != nil {
+			handleError(err, w, request)
+			return
+		}
+		body = bytes.TrimSpace(body)
+		var responseData any
+		if len(body) > 0 && body[0] == '[' {
+			var items []json.RawMessage
+			err = json.Unmarshal(body, &items)
+			switch {
+			case err != nil:
+				responseData = newJSONRPCErrorResponse(JSONRPCParseError, "Parse error")
+			case len(items) == 0:
+				responseData = newJSONRPCErrorResponse(JSONRPCInvalidRequest, "Invalid Request")
+			default:
+				responses := []*jsonrpcResponse{}
+				for _, item := range items {
+					response := callJSONRPCMethod(methods, r, item, config)
+					if response != nil {
+						responses = append(responses, response)
+					}
+				}
+				if len(responses) > 0 {
+					responseData = responses
+				}
+			}
+		} else {
+			response := callJSONRPCMethod(methods, r, body, config)
+			if response != nil {
+				responseData = response
+			}
+		}
+		if responseData == nil {
+			// only notifications
+			writeResponse(w, NoContent(), request, config)
+			return
+		}
+		resBody, err := json.Marshal(responseData)
+		if err != nil {
+			handleError(NewError(Internal, "", err), w, request)
+			return
+		}
+		writeResponse(w, &Response{
+			Data:   resBody,
+			Header: http.Header{"Content-Type": []string{jsonContentType}},
+		}, request, config)
+	}
+}
+
+func newJSONRPCErrorResponse(code int, message string) *jsonrpcResponse {
+	return &jsonrpcResponse{
+		JSONRPC: "2.0",
+		Error:   newJSONRPCError(code, message),
+		ID:      json.RawMessage("null"),
+	}
+}
+
+// callJSONRPCMethod: returns nil for notifications
+func callJSONRPCMethod(methods map[string]Handler, r *http.Request, item []byte, config *handlerConfig) *jsonrpcResponse {
+	fields := map[string]json.RawMessage{}
+	err := json.Unmarshal(item, &fields)
+	if err != nil {
+		var value any
+		if json.Unmarshal(item, &value) != nil {
+			return newJSONRPCErrorResponse(JSONRPCParseError, "Parse error")
+		}
+		// valid json, but not an object
+		return newJSONRPCErrorResponse(JSONRPCInvalidRequest, "Invalid Request")
+	}
+	id, hasID := fields["id"]
+	if hasID && !isValidJSONRPCID(id) {
+		return newJSONRPCErrorResponse(JSONRPCInvalidRequest, "Invalid Request")
+	}
+	response := &jsonrpcResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+	}
+	if !hasID {
+		response.ID = json.RawMessage("null")
+	}
+	var version, methodName string
+	if json.Unmarshal(fields["jsonrpc"], &version) != nil || version != "2.0" {
+		response.Error = newJSONRPCError(JSONRPCInvalidRequest, "Invalid Request")
+		return response
+	}
+	if json.Unmarshal(fields["method"], &methodName) != nil || methodName == "" {
+		response.Error = newJSONRPCError(JSONRPCInvalidRequest, "Invalid Request")
+		return response
+	}
+	params := bytes.TrimSpace(fields["params"])
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		params = []byte("{}")
+	}
+	handler, ok := methods[methodName]
+	if !ok {
+		response.Error = newJSONRPCError(JSONRPCMethodNotFound, "Method not found")
+		if !hasID {
+			return nil
+		}
+		return response
+	}
+	if params[0] != '{' {
+		response.Error = newJSONRPCError(JSONRPCInvalidParams, "Invalid params")
+		response.Error.Data = &jsonrpcErrorData{
+			Code:    InvalidArgument.String(),
+			Details: map[string]any{"reason": "params must be an object"},
+		}
+		if !hasID {
+			return nil
+		}
+		return response
+	}
+	callR := r.Clone(r.Context())
+	callR.Body = nil
+	callR.ContentLength = int64(len(params))
+	callR.Header.Set("Content-Type", "application/json")
+	callR.Header.Del("Content-Encoding")
+	handlerName := handlerFunctionName(handler)
+	request := &requestImp{
+		r:           callR,
+		handlerName: handlerName,
+		maxBodySize: config.getMaxBodySize(),
+		logger:      config.getLogger(),
+		requestID:   RequestIDFromContext(callR.Context()),
+		body:        params,
+	}
+	res, err := callHandler(handler, request)
+	if res == nil && err == nil {
+		err = NewError(Internal, "", fmt.Errorf("handler %v returned nil response with nil error", handlerName))
+	}
+	if err == nil {
+		response.Result, err = encodeJSONRPCResult(res)
+	}
+	if err != nil {
+		rpcErr := asRPCError(err, request.Logger())
+		addRequestDetails(rpcErr, request)
+		response.Error = jsonrpcErrorFromRPCError(rpcErr, request.requestID)
+		errorDispatcher(request, rpcErr)
+	}
+	if !hasID {
+		return nil
+	}
+	return response
+}
+
+func encodeJSONRPCResult(res *Response) (json.RawMessage, error) {
+	_, isFile := res.Data.(*File)
+	if isFile || isStreamData(res.Data) {
+		return nil, NewError(Internal, "", fmt.Errorf("unsupported response data type %T for JSON-RPC", res.Data))
+	}
+	if res.Data == nil {
+		return json.RawMessage("null"), nil
+	}
+	result, err := json.Marshal(res.Data)
+	if err != nil {
+		return nil, NewError(Internal, "", err)
+	}
+	return result, nil
+}
+
+func isValidJSONRPCID(id json.RawMessage) bool {
+	var value any
+	err := json.Unmarshal(id, &value)
+	if err != nil {
+		return false
+	}
+	switch value.(type) {
+	case nil, string, float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package ripo
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+var jsonrpcTestMethods = map[string]Handler{
+	"hello": func(req Request) (*Response, error) {
+		name, err := req.GetString("name", FromBody)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{Data: map[string]string{"greeting": "Hello " + *name}}, nil
+	},
+	"subtract": func(req Request) (*Response, error) {
+		model := struct {
+			A int `json:"a"`
+			B int `json:"b"`
+		}{}
+		err := req.BodyTo(&model)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{Data: model.A - model.B}, nil
+	},
+	"find": func(req Request) (*Response, error) {
+		return nil, NewError(NotFound, "user not found", nil).AddPublic("userId", "123")
+	},
+	"crash": func(req Request) (*Response, error) {
+		panic("boom")
+	},
+}
+
+var jsonrpcTestHeader = http.Header{"Content-Type": {"application/json"}}
+
+func TestJSONRPC_Single(t *testing.T) {
+	is := is.New(t)
+	{
+		w := doTestRequest(JSONRPCHandler(jsonrpcTestMethods), "POST", "/rpc", jsonrpcTestHeader, `{"jsonrpc":"2.0","method":"hello","params":{"name":"John"},"id":1}`)
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal(jsonContentType, w.Header().Get("Content-Type"))
+		is.Equal(`{"jsonrpc":"2.0","result":{"greeting":"Hello John"},"id":1}`, w.Body.String())
+	}
+	{
+		w := doTestRequest(JSONRPCHandler(jsonrpcTestMethods), "POST", "/rpc", jsonrpcTestHeader, `{"jsonrpc":"2.0","method":"subtract","params":{"a":42,"b":23},"id":"abc"}`)
+		is.Equal(`{"jsonrpc":"2.0","result":19,"id":"abc"}`, w.Body.String())
+	}
+}
+
+func TestJSONRPC_Errors(t *testing.T) {
+	is := is.New(t)
+	for body, expected := range map[string]string{
+		`{"jsonrpc":"2.0","method":"hello","params":{},"id":1}`: `{"jsonrpc":"2.0","error":{"code":-32602,` +
+			`"message":"missing 'name'","data":{"code":"MissingArgument"}},"id":1}`,
+		`{"jsonrpc":"2.0","method":"find","id":2}`: `{"jsonrpc":"2.0","error":{"code":-32005,` +
+			`"message":"user not found","data":{"code":"NotFound","details":{"userId":"123"}}},"id":2}`,
+		`{"jsonrpc":"2.0","method":"crash","id":3}`: `{"jsonrpc":"2.0","error":{"code":-32603,` +
+			`"message":"Internal","data":{"code":"Internal"}},"id":3}`,
+		`{"jsonrpc":"2.0","method":"missing","id":4}`: `{"jsonrpc":"2.0","error":{"code":-32601,` +
+			`"message":"Method not found"},"id":4}`,
+		`{"jsonrpc":"2.0","method":"hello","params":["John"],"id":5}`: `{"jsonrpc":"2.0","error":{"code":-32602,` +
+			`"message":"Invalid params","data":{"code":"InvalidArgument","details":{"reason":"params must be an object"}}},"id":5}`,
+		`{"jsonrpc":"1.0","method":"hello","id":6}`: `{"jsonrpc":"2.0","error":{"code":-32600,` +
+			`"message":"Invalid Request"},"id":6}`,
+		`{"jsonrpc":"2.0","method":"hello","id":{}}`: `{"jsonrpc":"2.0","error":{"code":-32600,` +
+			`"message":"Invalid Request"},"id":null}`,
+		`{"jsonrpc":"2.0","method"`: `{"jsonrpc":"2.0","error":{"code":-32700,` +
+			`"message":"Parse error"},"id":null}`,
+		`[]`: `{"jsonrpc":"2.0","error":{"code":-32600,` +
+			`"message":"Invalid Request"},"id":null}`,
+	} {
+		w := doTestRequest(JSONRPCHandler(jsonrpcTestMethods), "POST", "/rpc", jsonrpcTestHeader, body)
+		is.Msg("body=%v", body).Equal(http.StatusOK, w.Code)
+		is.Msg("body=%v", body).Equal(expected, w.Body.String())
+	}
+}
+
+func TestJSONRPC_Batch(t *testing.T) {
+	is := is.New(t)
+	var dispatched []RPCError
+	SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {
+		dispatched = append(dispatched, rpcErr)
+	})
+	defer SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {})
+	w := doTestRequest(JSONRPCHandler(jsonrpcTestMethods), "POST", "/rpc", jsonrpcTestHeader, `[
+		{"jsonrpc":"2.0","method":"subtract","params":{"a":1,"b":2},"id":1},
+		{"jsonrpc":"2.0","method":"hello","params":{"name":"notify"}},
+		{"jsonrpc":"2.0","method":"find"},
+		1,
+		{"jsonrpc":"2.0","method":"hello","params":{"name":"Jane"},"id":2}
+	]`)
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal(
+		`[{"jsonrpc":"2.0","result":-1,"id":1},`+
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null},`+
+			`{"jsonrpc":"2.0","result":{"greeting":"Hello Jane"},"id":2}]`,
+		w.Body.String(),
+	)
+	// error of notification is not sent, but dispatched
+	is.Equal(1, len(dispatched))
+	is.Equal(NotFound, dispatched[0].Code())
+}
+
+func TestJSONRPC_Notifications(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(JSONRPCHandler(jsonrpcTestMethods), "POST", "/rpc", jsonrpcTestHeader, `[
+		{"jsonrpc":"2.0","method":"hello","params":{"name":"a"}},
+		{"jsonrpc":"2.0","method":"missing"}
+	]`)
+	is.Equal(http.StatusNoContent, w.Code)
+	is.Equal("", w.Body.String())
+}
+
+func TestJSONRPC_Method(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(JSONRPCHandler(jsonrpcTestMethods), "GET", "/rpc", jsonrpcTestHeader, "")
+	is.Equal(http.StatusMethodNotAllowed, w.Code)
+	is.Equal("POST", w.Header().Get("Allow"))
+	is.Equal("{\"code\":\"Unimplemented\",\"error\":\"JSON-RPC requests must use POST method\"}", w.Body.String())
+}
+
+func TestJSONRPCErrorCode(t *testing.T) {
+	is := is.New(t)
+	is.Equal(JSONRPCInvalidParams, JSONRPCErrorCode(InvalidArgument))
+	is.Equal(JSONRPCInternalError, JSONRPCErrorCode(Unknown))
+	is.Equal(-32016, JSONRPCErrorCode(Unauthenticated))
+}
@@ -0,0 +1,186 @@
+package ripo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	lockRetryMinInterval = 5 * time.Millisecond
+	lockRetryMaxInterval = 100 * time.Millisecond
+)
+
+// LockInfo: a lock of a key
+type LockInfo struct {
+	Owner   string    // unique token of the request that holds the lock
+	Holder  string    // description of holder, request ID of the request, or its handler name
+	Expires time.Time // lock is released automatically after Expires
+}
+
+// LockBackend: keeps locks of keys, implement it for external stores
+type LockBackend interface {
+	// TryLock: acquires lock of key if it's free, expired or already owned by lock.Owner
+	// (then its expiry is updated), returns the current lock of key, so lock is acquired
+	// if owner of returned lock is lock.Owner
+	TryLock(ctx context.Context, key string, lock *LockInfo) (*LockInfo, error)
+
+	// Unlock: releases lock of key, if it's owned by owner
+	Unlock(ctx context.Context, key string, owner string) error
+}
+
+// MemoryLockBackend: in-memory LockBackend, for a single server
+type MemoryLockBackend struct {
+	mutex sync.Mutex
+	locks map[string]*LockInfo
+}
+
+func NewMemoryLockBackend() *MemoryLockBackend {
+	return &MemoryLockBackend{
+		locks: map[string]*LockInfo{},
+	}
+}
+
+func (b *MemoryLockBackend) TryLock(ctx context.Context, key string, lock *LockInfo) (*LockInfo, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	current := b.locks[key]
+	if current != nil && current.Owner != lock.Owner && time.Now().Before(current.Expires) {
+		info := *current
+		return &info, nil
+	}
+	info := *lock
+	b.locks[key] = &info
+	return lock, nil
+}
+
+func (b *MemoryLockBackend) Unlock(ctx context.Context, key string, owner string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	current := b.locks[key]
+	if current != nil && current.Owner == owner {
+		delete(b.locks, key)
+	}
+	return nil
+}
+
+var lockBackend LockBackend = NewMemoryLockBackend()
+
+// SetLockBackend: set backend of req.Lock, default is an in-memory backend
+func SetLockBackend(backend LockBackend) {
+	if backend == nil {
+		panic("SetLockBackend: nil backend")
+	}
+	lockBackend = backend
+}
+
+// lockWaitTimeout: how long req.Lock waits for a held lock
+var lockWaitTimeout = time.Second
+
+// SetLockWaitTimeout: set how long req.Lock waits for a lock that is held by another request
+// before returning ResourceLocked error, 0 means no waiting, default is 1 second
+func SetLockWaitTimeout(timeout time.Duration) {
+	if timeout < 0 {
+		panic("SetLockWaitTimeout: negative timeout")
+	}
+	lockWaitTimeout = timeout
+}
+
+// Lock: acquires lock of key for ttl, waiting for it if it's held by another request
+// returns ResourceLocked error if lock is not released within wait timeout (see SetLockWaitTimeout)
+// with key and expiry of lock in public details, and holder in (private) details
+// locks are released when handler returns, calling Lock again for the same key extends the lock
+func (req *requestImp) Lock(ctx context.Context, key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return NewError(Internal, "", fmt.Errorf("invalid lock ttl %v", ttl)).Add("key", key)
+	}
+	req.lockMutex.Lock()
+	if req.lockOwner == "" {
+		req.lockOwner = randomHex(16)
+	}
+	owner := req.lockOwner
+	req.lockMutex.Unlock()
+	holder := req.requestID
+	if holder == "" {
+		holder = req.handlerName
+	}
+	deadline := time.Now().Add(lockWaitTimeout)
+	interval := lockRetryMinInterval
+	expiredRetried := false
+	for {
+		lock := &LockInfo{
+			Owner:   owner,
+			Holder:  holder,
+			Expires: time.Now().Add(ttl),
+		}
+		current, err := lockBackend.TryLock(ctx, key, lock)
+		if err != nil {
+			return NewError(Unavailable, "", err).Add("key", key)
+		}
+		if current.Owner == owner {
+			req.addLock(key)
+			return nil
+		}
+		untilDeadline := time.Until(deadline)
+		if untilDeadline <= 0 {
+			return NewError(
+				ResourceLocked, fmt.Sprintf("resource %#v is locked", key), nil,
+			).AddPublic(
+				"key", key,
+			).AddPublic(
+				"expires", current.Expires.UTC().Format(time.RFC3339Nano),
+			).Add("holder", current.Holder)
+		}
+		wait := min(interval, untilDeadline)
+		untilExpires := time.Until(current.Expires)
+		if untilExpires <= 0 && !expiredRetried {
+			// lock has just expired, retry immediately
+			expiredRetried = true
+			continue
+		}
+		if untilExpires > 0 {
+			wait = min(wait, untilExpires)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return NewError(Canceled, "", ctx.Err()).Add("key", key)
+		}
+		interval = min(2*interval, lockRetryMaxInterval)
+	}
+}
+
+func (req *requestImp) addLock(key string) {
+	req.lockMutex.Lock()
+	defer req.lockMutex.Unlock()
+	for _, other := range req.lockKeys {
+		if other == key {
+			return
+		}
+	}
+	req.lockKeys = append(req.lockKeys, key)
+}
+
+// releaseLocks: releases locks of request, called when handler returns
+func (req *requestImp) releaseLocks() {
+	req.lockMutex.Lock()
+	keys := req.lockKeys
+	req.lockKeys = nil
+	owner := req.lockOwner
+	req.lockMutex.Unlock()
+	for _, key := range keys {
+		// request context may be canceled already
+		err := lockBackend.Unlock(context.Background(), key, owner)
+		if err != nil {
+			req.Logger().Warn(
+				"error in releasing lock",
+				slog.String("key", key),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package ripo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ilius/is/v2"
+)
+
+type errorLockBackend struct{}
+
+func (errorLockBackend) TryLock(ctx context.Context, key string, lock *LockInfo) (*LockInfo, error) {
+	return nil, fmt.Errorf("connection refused")
+}
+
+func (errorLockBackend) Unlock(ctx context.Context, key string, owner string) error {
+	return fmt.Errorf("connection refused")
+}
+
+func setTestLockBackend(backend LockBackend, timeout time.Duration) func() {
+	oldBackend, oldTimeout := lockBackend, lockWaitTimeout
+	SetLockBackend(backend)
+	SetLockWaitTimeout(timeout)
+	return func() {
+		lockBackend, lockWaitTimeout = oldBackend, oldTimeout
+	}
+}
+
+func TestLock_ReleasedAfterHandler(t *testing.T) {
+	is := is.New(t)
+	backend := NewMemoryLockBackend()
+	defer setTestLockBackend(backend, 0)()
+	handlerFunc := TranslateHandler(func(req Request) (*Response, error) {
+		err := req.Lock(req.Context(), "order:123", time.Minute)
+		if err != nil {
+			return nil, err
+		}
+		// locking again extends the lock
+		err = req.Lock(req.Context(), "order:123", 2*time.Minute)
+		if err != nil {
+			return nil, err
+		}
+		is.Equal(1, len(backend.locks))
+		is.True(time.Until(backend.locks["order:123"].Expires) > time.Minute)
+		return NoContent(), nil
+	})
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		handlerFunc(w, httptest.NewRequest("POST", "/", nil))
+		is.Equal(http.StatusNoContent, w.Code)
+		is.Equal(0, len(backend.locks))
+	}
+}
+
+func TestLock_Contention(t *testing.T) {
+	is := is.New(t)
+	backend := NewMemoryLockBackend()
+	defer setTestLockBackend(backend, 20*time.Millisecond)()
+	expires := time.Now().Add(time.Minute)
+	backend.locks["order:123"] = &LockInfo{
+		Owner:   "other",
+		Holder:  "req-1",
+		Expires: expires,
+	}
+	origErrorDispatcher := errorDispatcher
+	defer func() {
+		errorDispatcher = origErrorDispatcher
+	}()
+	var rpcErr RPCError
+	errorDispatcher = func(request ExtendedRequest, rpcErrArg RPCError) {
+		rpcErr = rpcErrArg
+	}
+	handlerFunc := TranslateHandler(func(req Request) (*Response, error) {
+		err := req.Lock(req.Context(), "order:123", time.Minute)
+		if err != nil {
+			return nil, err
+		}
+		return NoContent(), nil
+	})
+	start := time.Now()
+	w := httptest.NewRecorder()
+	handlerFunc(w, httptest.NewRequest("POST", "/", nil))
+	is.True(time.Since(start) >= 20*time.Millisecond)
+	is.Equal(HTTPStatusFromCode(ResourceLocked), w.Code)
+	body := map[string]any{}
+	is.NotErr(json.Unmarshal(w.Body.Bytes(), &body))
+	is.Equal("ResourceLocked", body["code"])
+	is.Equal(`resource "order:123" is locked`, body["error"])
+	is.Equal(map[string]any{
+		"key":     "order:123",
+		"expires": expires.UTC().Format(time.RFC3339Nano),
+	}, body["details"])
+	// holder is not exposed to client
+	is.Equal("req-1", rpcErr.Details()["holder"])
+	// lock of other request is not released
+	is.Equal("other", backend.locks["order:123"].Owner)
+}
+
+func TestLock_WaitForRelease(t *testing.T) {
+	is := is.New(t)
+	backend := NewMemoryLockBackend()
+	defer setTestLockBackend(backend, time.Second)()
+	first := &requestImp{handlerName: "first"}
+	second := &requestImp{handlerName: "second"}
+	ctx := context.Background()
+	is.NotErr(first.Lock(ctx, "k", time.Minute))
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		first.releaseLocks()
+	}()
+	is.NotErr(second.Lock(ctx, "k", time.Minute))
+	is.Equal("second", backend.locks["k"].Holder)
+	second.releaseLocks()
+	is.Equal(0, len(backend.locks))
+}
+
+func TestLock_Expired(t *testing.T) {
+	is := is.New(t)
+	backend := NewMemoryLockBackend()
+	defer setTestLockBackend(backend, 0)()
+	backend.locks["k"] = &LockInfo{
+		Owner:   "other",
+		Holder:  "req-1",
+		Expires: time.Now().Add(-time.Second),
+	}
+	request := &requestImp{requestID: "req-2"}
+	is.NotErr(request.Lock(context.Background(), "k", time.Minute))
+	is.Equal("req-2", backend.locks["k"].Holder)
+}
+
+func TestLock_ExpiresSoon(t *testing.T) {
+	is := is.New(t)
+	backend := NewMemoryLockBackend()
+	defer setTestLockBackend(backend, time.Second)()
+	backend.locks["k"] = &LockInfo{
+		Owner:   "other",
+		Holder:  "req-1",
+		Expires: time.Now().Add(3 * time.Millisecond),
+	}
+	request := &requestImp{requestID: "req-2"}
+	is.NotErr(request.Lock(context.Background(), "k", time.Minute))
+	is.Equal("req-2", backend.locks["k"].Holder)
+}
+
+// skewedLockBackend: reports the lock as held for a few calls after it has expired,
+// like a backend with a clock behind the clock of server
+type skewedLockBackend struct {
+	*MemoryLockBackend
+	heldCalls int
+}
+
+func (b *skewedLockBackend) TryLock(ctx context.Context, key string, lock *LockInfo) (*LockInfo, error) {
+	if b.heldCalls > 0 {
+		b.heldCalls--
+		return &LockInfo{
+			Owner:   "other",
+			Expires: time.Now().Add(-time.Millisecond),
+		}, nil
+	}
+	return b.MemoryLockBackend.TryLock(ctx, key, lock)
+}
+
+func TestLock_ExpiredInBackend(t *testing.T) {
+	is := is.New(t)
+	for _, heldCalls := range []int{1, 3} {
+		backend := &skewedLockBackend{
+			MemoryLockBackend: NewMemoryLockBackend(),
+			heldCalls:         heldCalls,
+		}
+		restore := setTestLockBackend(backend, time.Second)
+		request := &requestImp{requestID: "req-2"}
+		is.NotErr(request.Lock(context.Background(), "k", time.Minute))
+		is.Equal(0, backend.heldCalls)
+		is.Equal("req-2", backend.locks["k"].Holder)
+		restore()
+	}
+}
+
+func TestLock_Canceled(t *testing.T) {
+	backend := NewMemoryLockBackend()
+	defer setTestLockBackend(backend, time.Second)()
+	backend.locks["k"] = &LockInfo{
+		Owner:   "other",
+		Expires: time.Now().Add(time.Minute),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := (&requestImp{}).Lock(ctx, "k", time.Minute)
+	AssertError(t, err, Canceled, "Canceled")
+}
+
+func TestLock_BackendError(t *testing.T) {
+	is := is.New(t)
+	defer setTestLockBackend(errorLockBackend{}, 0)()
+	err := (&requestImp{}).Lock(context.Background(), "k", time.Minute)
+	AssertError(t, err, Unavailable, "Unavailable")
+	is.Equal("k", err.(RPCError).Details()["key"])
+}
+
+func TestLock_InvalidTTL(t *testing.T) {
+	err := (&requestImp{}).Lock(context.Background(), "k", 0)
+	AssertError(t, err, Internal, "Internal")
+}
+
+func TestSetLockBackend_Invalid(t *testing.T) {
+	is := is.New(t)
+	is.ShouldPanic(func() {
+		SetLockBackend(nil)
+	})
+	is.ShouldPanic(func() {
+		SetLockWaitTimeout(-time.Second)
+	})
+}
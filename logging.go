@@ -0,0 +1,73 @@
+package ripo
+
+import (
+	"log/slog"
+)
+
+// logger: global logger, nil means slog.Default()
+var logger *slog.Logger
+
+// SetLogger: set global logger of internal logs and default error dispatcher
+// nil means slog.Default(), can be overridden per handler with Logger option
+func SetLogger(l *slog.Logger) {
+	logger = l
+}
+
+func getLogger() *slog.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
+
+// Logger: set logger of this handler (or all handlers of router), overriding global SetLogger
+func Logger(l *slog.Logger) HandlerOption {
+	return func(config *handlerConfig) {
+		config.logger = l
+	}
+}
+
+func (config *handlerConfig) getLogger() *slog.Logger {
+	if config.logger == nil {
+		return getLogger()
+	}
+	return config.logger
+}
+
+// Logger: returns logger of handler, with handler name and request metadata as attributes
+func (req *requestImp) Logger() *slog.Logger {
+	l := req.logger
+	if l == nil {
+		l = getLogger()
+	}
+	attrs := []any{
+		slog.String("handler", req.handlerName),
+	}
+	if req.r != nil {
+		attrs = append(attrs,
+			slog.String("method", req.r.Method),
+			slog.String("path", req.r.URL.Path),
+			slog.String("remoteAddr", req.r.RemoteAddr),
+		)
+	}
+	if req.requestID != "" {
+		attrs = append(attrs, slog.String("requestId", req.requestID))
+	}
+	if req.r != nil {
+		sc := SpanContextFromContext(req.r.Context())
+		if sc != nil {
+			attrs = append(attrs,
+				slog.String("traceId", sc.TraceID),
+				slog.String("spanId", sc.SpanID),
+			)
+		}
+	}
+	return l.With(attrs...)
+}
+
+// logFrame: a traceback record, as logged by default error dispatcher
+type logFrame struct {
+	File string `json:"file"`
+	Func string `json:"func"`
+	Line int    `json:"line"`
+}
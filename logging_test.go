@@ -0,0 +1,106 @@
+package ripo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+func decodeLogLines(is *is.Is, buf *bytes.Buffer) []map[string]any {
+	records := []map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		record := map[string]any{}
+		is.NotErr(json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLogger_ErrorDispatcher(t *testing.T) {
+	is := is.New(t)
+	SetErrorDispatcher(defaultErrorDispatcher)
+	defer SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {})
+	buf := &bytes.Buffer{}
+	handlerFunc := TranslateHandler(func(req Request) (*Response, error) {
+		return nil, NewError(NotFound, "user not found", fmt.Errorf("no rows")).Add("userId", 12)
+	}, Logger(slog.New(slog.NewJSONHandler(buf, nil))))
+	r := httptest.NewRequest("GET", "/users/12", nil)
+	r.Header.Set(RequestIDHeader, "abc")
+	w := httptest.NewRecorder()
+	handlerFunc(w, r)
+	is.Equal(http.StatusNotFound, w.Code)
+	records := decodeLogLines(is, buf)
+	is.Equal(1, len(records))
+	record := records[0]
+	is.Equal("ERROR", record["level"])
+	is.Equal("RPCError", record["msg"])
+	is.Equal("NotFound", record["code"])
+	is.Equal("user not found", record["message"])
+	is.Equal("no rows", record["cause"])
+	is.Equal(map[string]any{"userId": float64(12), "requestId": "abc"}, record["details"])
+	is.Equal("GET", record["method"])
+	is.Equal("/users/12", record["path"])
+	is.Equal("abc", record["requestId"])
+	is.True(strings.Contains(record["handler"].(string), "TestLogger_ErrorDispatcher"))
+	frames, ok := record["traceback"].([]any)
+	is.True(ok)
+	is.True(len(frames) > 0)
+	frame := frames[0].(map[string]any)
+	is.True(strings.HasSuffix(frame["file"].(string), "logging_test.go"))
+	is.True(frame["line"].(float64) > 0)
+}
+
+func TestLogger_NonRPCError(t *testing.T) {
+	is := is.New(t)
+	buf := &bytes.Buffer{}
+	SetLogger(slog.New(slog.NewJSONHandler(buf, nil)))
+	defer SetLogger(nil)
+	handlerFunc := TranslateHandler(func(req Request) (*Response, error) {
+		return nil, fmt.Errorf("go away")
+	})
+	w := httptest.NewRecorder()
+	handlerFunc(w, httptest.NewRequest("GET", "/", nil))
+	is.Equal(http.StatusInternalServerError, w.Code)
+	records := decodeLogLines(is, buf)
+	is.Equal(1, len(records))
+	is.Equal("WARN", records[0]["level"])
+	is.Equal("handler returned non-rpc error", records[0]["msg"])
+	is.Equal("go away", records[0]["error"])
+	is.Equal("*errors.errorString", records[0]["errorType"])
+}
+
+func TestLogger_Router(t *testing.T) {
+	is := is.New(t)
+	SetErrorDispatcher(defaultErrorDispatcher)
+	defer SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {})
+	buf := &bytes.Buffer{}
+	router := NewRouter(Logger(slog.New(slog.NewJSONHandler(buf, nil))))
+	w := doTestRequest(router, "GET", "/missing", nil, "")
+	is.Equal(http.StatusNotFound, w.Code)
+	records := decodeLogLines(is, buf)
+	is.Equal(1, len(records))
+	is.Equal("Router", records[0]["handler"])
+	is.Equal("NotFound", records[0]["code"])
+}
+
+func TestLogger_Default(t *testing.T) {
+	is := is.New(t)
+	is.Equal(slog.Default(), getLogger())
+	l := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	SetLogger(l)
+	defer SetLogger(nil)
+	is.Equal(l, getLogger())
+	is.Equal(l, newHandlerConfig(nil).getLogger())
+	other := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	is.Equal(other, newHandlerConfig([]HandlerOption{Logger(other)}).getLogger())
+}
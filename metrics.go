@@ -0,0 +1,318 @@
+package ripo
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultDurationBuckets: default histogram buckets of request duration in seconds
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// DefaultSizeBuckets: default histogram buckets of request and response body size in bytes
+var DefaultSizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+
+var metricNamespaceRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative, last one is +Inf
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(buckets []float64, value float64) {
+	index := sort.SearchFloat64s(buckets, value)
+	h.counts[index]++
+	h.sum += value
+	h.count++
+}
+
+type metricsRequestKey struct {
+	handler string
+	method  string
+	code    string
+}
+
+type metricsHandlerKey struct {
+	handler string
+	method  string
+}
+
+// Metrics: collects metrics of handlers, exposed in Prometheus text format by Handler
+// metrics are: requests_total (by handler, method and code), request_duration_seconds,
+// request_size_bytes and response_size_bytes histograms (by handler and method)
+// and requests_in_flight gauge (by handler)
+// code is "OK" for successful requests, and name of error code otherwise
+type Metrics struct {
+	prefix          string
+	durationBuckets []float64
+	sizeBuckets     []float64
+
+	mutex         sync.Mutex
+	requests      map[metricsRequestKey]uint64
+	durations     map[metricsHandlerKey]*histogram
+	requestSizes  map[metricsHandlerKey]*histogram
+	responseSizes map[metricsHandlerKey]*histogram
+	inFlight      map[string]int64
+}
+
+// NewMetrics: namespace is prefix of metric names, like "myapp" for "myapp_requests_total"
+// empty namespace means no prefix
+func NewMetrics(namespace string) *Metrics {
+	prefix := ""
+	if namespace != "" {
+		if !metricNamespaceRegexp.MatchString(namespace) {
+			panic(fmt.Sprintf("NewMetrics: invalid namespace %#v", namespace))
+		}
+		prefix = namespace + "_"
+	}
+	return &Metrics{
+		prefix:          prefix,
+		durationBuckets: DefaultDurationBuckets,
+		sizeBuckets:     DefaultSizeBuckets,
+		requests:        map[metricsRequestKey]uint64{},
+		durations:       map[metricsHandlerKey]*histogram{},
+		requestSizes:    map[metricsHandlerKey]*histogram{},
+		responseSizes:   map[metricsHandlerKey]*histogram{},
+		inFlight:        map[string]int64{},
+	}
+}
+
+func checkBuckets(funcName string, buckets []float64) {
+	if len(buckets) == 0 {
+		panic(funcName + ": no buckets")
+	}
+	for index, bucket := range buckets {
+		if math.IsNaN(bucket) || math.IsInf(bucket, 0) {
+			panic(fmt.Sprintf("%v: invalid bucket %v", funcName, bucket))
+		}
+		if index > 0 && bucket <= buckets[index-1] {
+			panic(funcName + ": buckets must be in increasing order")
+		}
+	}
+}
+
+// DurationBuckets: set histogram buckets of request duration in seconds, must be called before use
+func (m *Metrics) DurationBuckets(buckets ...float64) *Metrics {
+	checkBuckets("Metrics.DurationBuckets", buckets)
+	m.durationBuckets = buckets
+	return m
+}
+
+// SizeBuckets: set histogram buckets of body sizes in bytes, must be called before use
+func (m *Metrics) SizeBuckets(buckets ...float64) *Metrics {
+	checkBuckets("Metrics.SizeBuckets", buckets)
+	m.sizeBuckets = buckets
+	return m
+}
+
+func (m *Metrics) startRequest(handlerName string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.inFlight[handlerName]++
+}
+
+// metricsMethods: methods that are used as method label, others are labeled as "other"
+// so clients can not create unlimited number of series
+var metricsMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+func metricsMethodLabel(method string) string {
+	if metricsMethods[method] {
+		return method
+	}
+	return "other"
+}
+
+func (m *Metrics) finishRequest(entry *AccessLogEntry, requestBytes int64) {
+	code := entry.Code
+	if code == "" {
+		code = "OK"
+	}
+	method := metricsMethodLabel(entry.Method)
+	handlerKey := metricsHandlerKey{
+		handler: entry.Handler,
+		method:  method,
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.inFlight[entry.Handler]--
+	m.requests[metricsRequestKey{
+		handler: entry.Handler,
+		method:  method,
+		code:    code,
+	}]++
+	m.getHistogram(m.durations, handlerKey, m.durationBuckets).observe(m.durationBuckets, entry.Latency.Seconds())
+	m.getHistogram(m.requestSizes, handlerKey, m.sizeBuckets).observe(m.sizeBuckets, float64(requestBytes))
+	m.getHistogram(m.responseSizes, handlerKey, m.sizeBuckets).observe(m.sizeBuckets, float64(entry.Bytes))
+}
+
+func (m *Metrics) getHistogram(histograms map[metricsHandlerKey]*histogram, key metricsHandlerKey, buckets []float64) *histogram {
+	h := histograms[key]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(buckets)+1)}
+		histograms[key] = h
+	}
+	return h
+}
+
+// Handler: serves metrics in Prometheus text exposition format
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", metricsContentType)
+		_, err := w.Write(m.expose())
+		if err != nil {
+			getLogger().Warn("error in writing metrics", slog.String("error", err.Error()))
+		}
+	})
+}
+
+func (m *Metrics) expose() []byte {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	buf := &bytes.Buffer{}
+
+	name := m.prefix + "requests_total"
+	writeMetricHeader(buf, name, "counter", "Total number of requests by handler, method and code.")
+	requestKeys := make([]metricsRequestKey, 0, len(m.requests))
+	for key := range m.requests {
+		requestKeys = append(requestKeys, key)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		a, b := requestKeys[i], requestKeys[j]
+		if a.handler != b.handler {
+			return a.handler < b.handler
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.code < b.code
+	})
+	for _, key := range requestKeys {
+		fmt.Fprintf(
+			buf, "%s{handler=%s,method=%s,code=%s} %d\n",
+			name,
+			quoteLabelValue(key.handler),
+			quoteLabelValue(key.method),
+			quoteLabelValue(key.code),
+			m.requests[key],
+		)
+	}
+
+	m.writeHistograms(buf, m.prefix+"request_duration_seconds", "Duration of requests in seconds.", m.durations, m.durationBuckets)
+	m.writeHistograms(buf, m.prefix+"request_size_bytes", "Size of request bodies in bytes.", m.requestSizes, m.sizeBuckets)
+	m.writeHistograms(buf, m.prefix+"response_size_bytes", "Size of response bodies in bytes.", m.responseSizes, m.sizeBuckets)
+
+	name = m.prefix + "requests_in_flight"
+	writeMetricHeader(buf, name, "gauge", "Number of requests that are being handled.")
+	handlers := make([]string, 0, len(m.inFlight))
+	for handler := range m.inFlight {
+		handlers = append(handlers, handler)
+	}
+	sort.Strings(handlers)
+	for _, handler := range handlers {
+		fmt.Fprintf(buf, "%s{handler=%s} %d\n", name, quoteLabelValue(handler), m.inFlight[handler])
+	}
+	return buf.Bytes()
+}
+
+func (m *Metrics) writeHistograms(
+	buf *bytes.Buffer,
+	name string,
+	help string,
+	histograms map[metricsHandlerKey]*histogram,
+	buckets []float64,
+) {
+	writeMetricHeader(buf, name, "histogram", help)
+	keys := make([]metricsHandlerKey, 0, len(histograms))
+	for key := range histograms {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].handler != keys[j].handler {
+			return keys[i].handler < keys[j].handler
+		}
+		return keys[i].method < keys[j].method
+	})
+	for _, key := range keys {
+		h := histograms[key]
+		labels := fmt.Sprintf("handler=%s,method=%s", quoteLabelValue(key.handler), quoteLabelValue(key.method))
+		var cumulative uint64
+		for index, count := range h.counts {
+			cumulative += count
+			le := "+Inf"
+			if index < len(buckets) {
+				le = formatMetricValue(buckets[index])
+			}
+			fmt.Fprintf(buf, "%s_bucket{%s,le=%q} %d\n", name, labels, le, cumulative)
+		}
+		fmt.Fprintf(buf, "%s_sum{%s} %s\n", name, labels, formatMetricValue(h.sum))
+		fmt.Fprintf(buf, "%s_count{%s} %d\n", name, labels, h.count)
+	}
+}
+
+func writeMetricHeader(buf *bytes.Buffer, name string, metricType string, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(buf, "# TYPE %s %s\n", name, metricType)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quoteLabelValue(value string) string {
+	return `"` + labelValueReplacer.Replace(value) + `"`
+}
+
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// metrics: global metrics, nil means disabled
+var metrics *Metrics
+
+// SetMetrics: set global metrics, nil disables metrics
+// Can be overridden per handler with Instrument and DisableMetrics options
+func SetMetrics(m *Metrics) {
+	metrics = m
+}
+
+// Instrument: record metrics of this handler in m, overriding global SetMetrics
+func Instrument(m *Metrics) HandlerOption {
+	return func(config *handlerConfig) {
+		config.metrics = m
+	}
+}
+
+// DisableMetrics: never record metrics of this handler
+func DisableMetrics() HandlerOption {
+	return func(config *handlerConfig) {
+		config.disableMetrics = true
+	}
+}
+
+func (config *handlerConfig) getMetrics() *Metrics {
+	if config.disableMetrics {
+		return nil
+	}
+	if config.metrics == nil {
+		return metrics
+	}
+	return config.metrics
+}
@@ -0,0 +1,176 @@
+package ripo
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ilius/is/v2"
+)
+
+func scrapeMetrics(is *is.Is, m *Metrics) string {
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+	res, err := http.Get(server.URL)
+	is.NotErr(err)
+	defer res.Body.Close()
+	is.Equal(http.StatusOK, res.StatusCode)
+	is.Equal(metricsContentType, res.Header.Get("Content-Type"))
+	body, err := io.ReadAll(res.Body)
+	is.NotErr(err)
+	return string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	is := is.New(t)
+	m := NewMetrics("test").DurationBuckets(0.1, 1)
+	mux := http.NewServeMux()
+	mux.Handle("/hello", TranslateHandler(metricsHelloHandler, Instrument(m)))
+	mux.Handle("/metrics", m.Handler())
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	for _, body := range []string{`{"name":"Jane"}`, `{"name":"John"}`, `{}`} {
+		res, err := http.Post(server.URL+"/hello", "application/json", strings.NewReader(body))
+		is.NotErr(err)
+		res.Body.Close()
+	}
+	res, err := http.Get(server.URL + "/metrics")
+	is.NotErr(err)
+	defer res.Body.Close()
+	bodyBytes, err := io.ReadAll(res.Body)
+	is.NotErr(err)
+	body := string(bodyBytes)
+	handler := `handler="github.com/ilius/ripo.metricsHelloHandler"`
+	for _, line := range []string{
+		"# TYPE test_requests_total counter",
+		`test_requests_total{` + handler + `,method="POST",code="OK"} 2`,
+		`test_requests_total{` + handler + `,method="POST",code="MissingArgument"} 1`,
+		"# TYPE test_request_duration_seconds histogram",
+		`test_request_duration_seconds_bucket{` + handler + `,method="POST",le="+Inf"} 3`,
+		`test_request_duration_seconds_count{` + handler + `,method="POST"} 3`,
+		`test_request_size_bytes_bucket{` + handler + `,method="POST",le="100"} 3`,
+		`test_request_size_bytes_sum{` + handler + `,method="POST"} 32`,
+		`test_response_size_bytes_sum{` + handler + `,method="POST"} 71`,
+		"# TYPE test_requests_in_flight gauge",
+		`test_requests_in_flight{` + handler + `} 0`,
+	} {
+		is.Msg("missing line %#v in:\n%v", line, body).True(strings.Contains(body, line+"\n"))
+	}
+}
+
+func metricsHelloHandler(req Request) (*Response, error) {
+	name, err := req.GetString("name", FromBody)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Data: "Hello " + *name}, nil
+}
+
+func TestMetrics_Histogram(t *testing.T) {
+	is := is.New(t)
+	m := NewMetrics("").DurationBuckets(1, 2).SizeBuckets(10)
+	for _, seconds := range []float64{0.5, 1, 1.5, 3} {
+		m.startRequest("h")
+		m.finishRequest(&AccessLogEntry{
+			Handler: "h",
+			Method:  "GET",
+			Code:    "NotFound",
+			Latency: durationFromSeconds(seconds),
+			Bytes:   20,
+		}, 0)
+	}
+	body := scrapeMetrics(is, m)
+	for _, line := range []string{
+		`requests_total{handler="h",method="GET",code="NotFound"} 4`,
+		`request_duration_seconds_bucket{handler="h",method="GET",le="1"} 2`,
+		`request_duration_seconds_bucket{handler="h",method="GET",le="2"} 3`,
+		`request_duration_seconds_bucket{handler="h",method="GET",le="+Inf"} 4`,
+		`request_duration_seconds_sum{handler="h",method="GET"} 6`,
+		`response_size_bytes_bucket{handler="h",method="GET",le="10"} 0`,
+		`response_size_bytes_bucket{handler="h",method="GET",le="+Inf"} 4`,
+		`requests_in_flight{handler="h"} 0`,
+	} {
+		is.Msg("missing line %#v in:\n%v", line, body).True(strings.Contains(body, line+"\n"))
+	}
+}
+
+func TestMetrics_InFlight(t *testing.T) {
+	is := is.New(t)
+	m := NewMetrics("")
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handlerFunc := TranslateHandler(func(req Request) (*Response, error) {
+		started <- struct{}{}
+		<-release
+		return NoContent(), nil
+	}, Instrument(m))
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handlerFunc(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}()
+		<-started
+	}
+	is.True(strings.Contains(scrapeMetrics(is, m), "requests_in_flight{handler=\"github.com/ilius/ripo.TestMetrics_InFlight.func1\"} 2\n"))
+	close(release)
+	wg.Wait()
+	is.True(strings.Contains(scrapeMetrics(is, m), "requests_in_flight{handler=\"github.com/ilius/ripo.TestMetrics_InFlight.func1\"} 0\n"))
+}
+
+func TestMetrics_UnknownMethod(t *testing.T) {
+	is := is.New(t)
+	m := NewMetrics("")
+	handlerFunc := TranslateHandler(func(req Request) (*Response, error) {
+		return NoContent(), nil
+	}, HandlerName("test"), Instrument(m))
+	for _, method := range []string{"FOO", "BAR", "get", "DELETE"} {
+		handlerFunc(httptest.NewRecorder(), httptest.NewRequest(method, "/", nil))
+	}
+	body := scrapeMetrics(is, m)
+	is.True(strings.Contains(body, `requests_total{handler="test",method="other",code="OK"} 3`+"\n"))
+	is.True(strings.Contains(body, `requests_total{handler="test",method="DELETE",code="OK"} 1`+"\n"))
+	is.False(strings.Contains(body, "FOO"))
+	is.Equal(2, len(m.requests))
+	is.Equal(2, len(m.durations))
+}
+
+func TestMetrics_Global(t *testing.T) {
+	is := is.New(t)
+	m := NewMetrics("")
+	SetMetrics(m)
+	defer SetMetrics(nil)
+	TranslateHandler(metricsHelloHandler)(httptest.NewRecorder(), httptest.NewRequest("GET", "/?name=x", nil))
+	TranslateHandler(metricsHelloHandler, DisableMetrics())(httptest.NewRecorder(), httptest.NewRequest("GET", "/?name=x", nil))
+	body := scrapeMetrics(is, m)
+	is.True(strings.Contains(body, `requests_total{handler="github.com/ilius/ripo.metricsHelloHandler",method="GET",code="MissingArgument"} 1`+"\n"))
+}
+
+func TestMetrics_Invalid(t *testing.T) {
+	is := is.New(t)
+	is.ShouldPanic(func() {
+		NewMetrics("my-app")
+	})
+	is.ShouldPanic(func() {
+		NewMetrics("").DurationBuckets()
+	})
+	is.ShouldPanic(func() {
+		NewMetrics("").DurationBuckets(1, 0.5)
+	})
+	is.ShouldPanic(func() {
+		NewMetrics("").SizeBuckets(1, 1)
+	})
+}
+
+func TestQuoteLabelValue(t *testing.T) {
+	is := is.New(t)
+	is.Equal(`"a\"b\\c\nd"`, quoteLabelValue("a\"b\\c\nd"))
+}
+func durationFromSeconds(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -6,6 +6,7 @@ package ripo
 import (
 	context "context"
 	gomock "github.com/golang/mock/gomock"
+	slog "log/slog"
 	http "net/http"
 	url "net/url"
 	reflect "reflect"
@@ -377,6 +378,49 @@ func (mr *MockRequestMockRecorder) Host() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Host", reflect.TypeOf((*MockRequest)(nil).Host))
 }
 
+// Lock mocks base method
+func (m *MockRequest) Lock(arg0 context.Context, arg1 string, arg2 time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lock", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Lock indicates an expected call of Lock
+func (mr *MockRequestMockRecorder) Lock(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lock", reflect.TypeOf((*MockRequest)(nil).Lock), arg0, arg1, arg2)
+}
+
+// Logger mocks base method
+func (m *MockRequest) Logger() *slog.Logger {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logger")
+	ret0, _ := ret[0].(*slog.Logger)
+	return ret0
+}
+
+// Logger indicates an expected call of Logger
+func (mr *MockRequestMockRecorder) Logger() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logger", reflect.TypeOf((*MockRequest)(nil).Logger))
+}
+
+// Pagination mocks base method
+func (m *MockRequest) Pagination() (*Pagination, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Pagination")
+	ret0, _ := ret[0].(*Pagination)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Pagination indicates an expected call of Pagination
+func (mr *MockRequestMockRecorder) Pagination() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pagination", reflect.TypeOf((*MockRequest)(nil).Pagination))
+}
+
 // RemoteIP mocks base method
 func (m *MockRequest) RemoteIP() (string, error) {
 	m.ctrl.T.Helper()
@@ -392,6 +436,20 @@ func (mr *MockRequestMockRecorder) RemoteIP() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoteIP", reflect.TypeOf((*MockRequest)(nil).RemoteIP))
 }
 
+// RequestID mocks base method
+func (m *MockRequest) RequestID() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestID")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// RequestID indicates an expected call of RequestID
+func (mr *MockRequestMockRecorder) RequestID() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestID", reflect.TypeOf((*MockRequest)(nil).RequestID))
+}
+
 // URL mocks base method
 func (m *MockRequest) URL() *url.URL {
 	m.ctrl.T.Helper()
@@ -800,6 +858,49 @@ func (mr *MockExtendedRequestMockRecorder) Host() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Host", reflect.TypeOf((*MockExtendedRequest)(nil).Host))
 }
 
+// Lock mocks base method
+func (m *MockExtendedRequest) Lock(arg0 context.Context, arg1 string, arg2 time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lock", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Lock indicates an expected call of Lock
+func (mr *MockExtendedRequestMockRecorder) Lock(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lock", reflect.TypeOf((*MockExtendedRequest)(nil).Lock), arg0, arg1, arg2)
+}
+
+// Logger mocks base method
+func (m *MockExtendedRequest) Logger() *slog.Logger {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logger")
+	ret0, _ := ret[0].(*slog.Logger)
+	return ret0
+}
+
+// Logger indicates an expected call of Logger
+func (mr *MockExtendedRequestMockRecorder) Logger() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logger", reflect.TypeOf((*MockExtendedRequest)(nil).Logger))
+}
+
+// Pagination mocks base method
+func (m *MockExtendedRequest) Pagination() (*Pagination, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Pagination")
+	ret0, _ := ret[0].(*Pagination)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Pagination indicates an expected call of Pagination
+func (mr *MockExtendedRequestMockRecorder) Pagination() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pagination", reflect.TypeOf((*MockExtendedRequest)(nil).Pagination))
+}
+
 // RemoteIP mocks base method
 func (m *MockExtendedRequest) RemoteIP() (string, error) {
 	m.ctrl.T.Helper()
@@ -815,6 +916,20 @@ func (mr *MockExtendedRequestMockRecorder) RemoteIP() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoteIP", reflect.TypeOf((*MockExtendedRequest)(nil).RemoteIP))
 }
 
+// RequestID mocks base method
+func (m *MockExtendedRequest) RequestID() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestID")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// RequestID indicates an expected call of RequestID
+func (mr *MockExtendedRequestMockRecorder) RequestID() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestID", reflect.TypeOf((*MockExtendedRequest)(nil).RequestID))
+}
+
 // URL mocks base method
 func (m *MockExtendedRequest) URL() *url.URL {
 	m.ctrl.T.Helper()
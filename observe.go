@@ -0,0 +1,162 @@
+package ripo
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// observedWriter: records status, size and error code of response for access log and metrics
+type observedWriter struct {
+	http.ResponseWriter
+	mutex  sync.Mutex
+	status int
+	bytes  int64
+	code   Code
+	hasErr bool
+}
+
+func (w *observedWriter) WriteHeader(status int) {
+	w.mutex.Lock()
+	if w.status == 0 && status >= 200 {
+		w.status = status
+	}
+	w.mutex.Unlock()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *observedWriter) Write(data []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(data)
+	w.mutex.Lock()
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.bytes += int64(n)
+	w.mutex.Unlock()
+	return n, err
+}
+
+func (w *observedWriter) Flush() {
+	flusher, ok := w.ResponseWriter.(http.Flusher)
+	if ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap: used by http.ResponseController
+func (w *observedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// setObservedCode: records error code of response, if w is observed
+func setObservedCode(w http.ResponseWriter, code Code) {
+	ow, ok := w.(*observedWriter)
+	if !ok {
+		return
+	}
+	ow.mutex.Lock()
+	ow.code = code
+	ow.hasErr = true
+	ow.mutex.Unlock()
+}
+
+// countingReadCloser: counts bytes of request body that are read
+type countingReadCloser struct {
+	io.ReadCloser
+	mutex sync.Mutex
+	bytes int64
+}
+
+func (rc *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := rc.ReadCloser.Read(p)
+	rc.mutex.Lock()
+	rc.bytes += int64(n)
+	rc.mutex.Unlock()
+	return n, err
+}
+
+func (rc *countingReadCloser) count() int64 {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	return rc.bytes
+}
+
+// observeRequest: wraps w (and body of r) to record request and response, if access log,
+// metrics or tracing are enabled for handler, and opens a span if tracing is enabled
+// returned function writes access log, metrics and span, and must be called after response is written
+func observeRequest(w http.ResponseWriter, r *http.Request, config *handlerConfig, handlerName string) (http.ResponseWriter, *http.Request, func()) {
+	al := config.getAccessLogger()
+	m := config.getMetrics()
+	exporter := config.getSpanExporter()
+	if al == nil && m == nil && exporter == nil {
+		return w, r, func() {}
+	}
+	start := time.Now()
+	var span *Span
+	if exporter != nil {
+		name := routePatternFromContext(r.Context())
+		if name == "" {
+			name = handlerName
+		}
+		r, span = startSpan(r, name)
+	}
+	var body *countingReadCloser
+	if m != nil {
+		m.startRequest(handlerName)
+		if r.Body != nil {
+			body = &countingReadCloser{ReadCloser: r.Body}
+			r = r.WithContext(r.Context())
+			r.Body = body
+		}
+	}
+	ow := &observedWriter{ResponseWriter: w}
+	return ow, r, func() {
+		remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			remoteIP = r.RemoteAddr
+		}
+		ow.mutex.Lock()
+		entry := &AccessLogEntry{
+			Time:      start,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Route:     routePatternFromContext(r.Context()),
+			Handler:   handlerName,
+			Status:    ow.status,
+			Bytes:     ow.bytes,
+			Latency:   time.Since(start),
+			RemoteIP:  remoteIP,
+			RequestID: w.Header().Get(RequestIDHeader),
+			UserAgent: r.UserAgent(),
+			Referer:   r.Referer(),
+
+			requestURI: r.RequestURI,
+			proto:      r.Proto,
+		}
+		if ow.hasErr {
+			entry.Code = ow.code.String()
+		}
+		ow.mutex.Unlock()
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK
+		}
+		if entry.requestURI == "" {
+			entry.requestURI = r.URL.RequestURI()
+		}
+		if m != nil {
+			var requestBytes int64
+			if body != nil {
+				requestBytes = body.count()
+			}
+			m.finishRequest(entry, requestBytes)
+		}
+		if al != nil {
+			al.Log(entry)
+		}
+		if span != nil {
+			finishSpan(exporter, span, entry)
+		}
+	}
+}
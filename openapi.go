@@ -0,0 +1,331 @@
+package ripo
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPIInfo: info object of OpenAPI document
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+const openAPIErrorSchemaName = "Error"
+
+var anonymousFuncRegexp = regexp.MustCompile(`^func[0-9]+$`)
+
+// OpenAPI: returns OpenAPI 3.1 document for registered routes, can be encoded as json
+// request and response schemas are generated from types given to route.Body and route.Returns
+// using json tags, and error responses are generated for route.Errors codes using HTTPStatusFromCode
+// InvalidArgument and MissingArgument are added for routes with parameters, and Internal for all routes
+func (router *Router) OpenAPI(info *OpenAPIInfo) map[string]any {
+	g := &openAPIGenerator{
+		schemas:     map[string]any{},
+		schemaNames: map[reflect.Type]string{},
+	}
+	paths := map[string]any{}
+	for _, route := range router.routes {
+		pathItem, ok := paths[route.pattern].(map[string]any)
+		if !ok {
+			pathItem = map[string]any{}
+			paths[route.pattern] = pathItem
+		}
+		pathItem[strings.ToLower(route.method)] = g.operation(route)
+	}
+	g.schemas[openAPIErrorSchemaName] = openAPIErrorSchema()
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info":    info,
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": g.schemas,
+		},
+	}
+}
+
+// OpenAPIHandler: handler that responds with OpenAPI document of router
+func (router *Router) OpenAPIHandler(info *OpenAPIInfo) Handler {
+	return func(req Request) (*Response, error) {
+		return &Response{
+			Data: router.OpenAPI(info),
+		}, nil
+	}
+}
+
+func openAPIErrorSchema() map[string]any {
+	names := make([]any, 0, len(ErrorCodeByName))
+	for name := range ErrorCodeByName {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i].(string) < names[j].(string)
+	})
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"code":      map[string]any{"type": "string", "enum": names},
+			"error":     map[string]any{"type": "string"},
+			"details":   map[string]any{"type": "object"},
+			"requestId": map[string]any{"type": "string"},
+		},
+		"required": []string{"code", "error"},
+	}
+}
+
+type openAPIGenerator struct {
+	schemas     map[string]any
+	schemaNames map[reflect.Type]string
+}
+
+var invalidSchemaNameRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// schemaName: returns component name of named type, made of package path and type name
+// sanitized to characters allowed in component names, and unique per type
+func (g *openAPIGenerator) schemaName(t reflect.Type) string {
+	name, ok := g.schemaNames[t]
+	if ok {
+		return name
+	}
+	fullName := t.Name()
+	if t.PkgPath() != "" {
+		fullName = t.PkgPath() + "." + fullName
+	}
+	base := strings.TrimRight(invalidSchemaNameRegexp.ReplaceAllString(fullName, "_"), "_")
+	name = base
+	for index := 2; ; index++ {
+		_, exists := g.schemas[name]
+		if !exists {
+			break
+		}
+		name = base + "_" + strconv.Itoa(index)
+	}
+	g.schemaNames[t] = name
+	return name
+}
+
+func (g *openAPIGenerator) operation(route *Route) map[string]any {
+	op := map[string]any{}
+	operationID := route.operationID
+	if operationID == "" {
+		name := handlerFunctionName(route.handler)
+		name = name[strings.LastIndex(name, ".")+1:]
+		if !anonymousFuncRegexp.MatchString(name) {
+			operationID = name
+		}
+	}
+	if operationID != "" {
+		op["operationId"] = operationID
+	}
+	if route.summary != "" {
+		op["summary"] = route.summary
+	}
+	if route.description != "" {
+		op["description"] = route.description
+	}
+	if len(route.tags) > 0 {
+		op["tags"] = route.tags
+	}
+	parameters := []any{}
+	bodyParams := []*Param{}
+	for _, param := range route.params {
+		if param.In == ParamInBody {
+			bodyParams = append(bodyParams, param)
+			continue
+		}
+		parameter := map[string]any{
+			"name":     param.Name,
+			"in":       param.In,
+			"required": param.Required || param.In == ParamInPath,
+			"schema":   g.paramSchema(param),
+		}
+		if param.Description != "" {
+			parameter["description"] = param.Description
+		}
+		parameters = append(parameters, parameter)
+	}
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+	var bodySchema map[string]any
+	switch {
+	case route.bodyType != nil:
+		bodySchema = g.schema(route.bodyType)
+	case len(bodyParams) > 0:
+		properties := map[string]any{}
+		required := []string{}
+		for _, param := range bodyParams {
+			properties[param.Name] = g.paramSchema(param)
+			if param.Required {
+				required = append(required, param.Name)
+			}
+		}
+		bodySchema = map[string]any{
+			"type":       "object",
+			"properties": properties,
+		}
+		if len(required) > 0 {
+			bodySchema["required"] = required
+		}
+	}
+	if bodySchema != nil {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": bodySchema},
+			},
+		}
+	}
+	responses := map[string]any{}
+	okResponse := map[string]any{"description": "OK"}
+	if route.resultType != nil {
+		okResponse["content"] = map[string]any{
+			"application/json": map[string]any{"schema": g.schema(route.resultType)},
+		}
+	}
+	responses["200"] = okResponse
+	codes := append([]Code{}, route.errorCodes...)
+	if len(route.params) > 0 || bodySchema != nil {
+		codes = append(codes, InvalidArgument, MissingArgument)
+	}
+	codes = append(codes, Internal)
+	codeNamesByStatus := map[int][]string{}
+	for _, code := range codes {
+		status := HTTPStatusFromCode(code)
+		name := code.String()
+		found := false
+		for _, other := range codeNamesByStatus[status] {
+			if other == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			codeNamesByStatus[status] = append(codeNamesByStatus[status], name)
+		}
+	}
+	for status, names := range codeNamesByStatus {
+		responses[strconv.Itoa(status)] = map[string]any{
+			"description": http.StatusText(status) + ": " + strings.Join(names, ", "),
+			"content": map[string]any{
+				"application/json": map[string]any{
+					"schema": map[string]any{"$ref": "#/components/schemas/" + openAPIErrorSchemaName},
+				},
+			},
+		}
+	}
+	op["responses"] = responses
+	return op
+}
+
+func (g *openAPIGenerator) paramSchema(param *Param) map[string]any {
+	if param.Type == nil {
+		return map[string]any{"type": "string"}
+	}
+	return g.schema(param.Type)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema: returns json schema of type, named struct types are added to components (see schemaName)
+func (g *openAPIGenerator) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32:
+		return map[string]any{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		_, ok := g.schemaNames[t]
+		name := g.schemaName(t)
+		if !ok {
+			// set before generating, for recursive types
+			g.schemas[name] = map[string]any{}
+			g.schemas[name] = g.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	// interface and others
+	return map[string]any{}
+}
+
+func (g *openAPIGenerator) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	g.addStructProperties(t, properties, &required)
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (g *openAPIGenerator) addStructProperties(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		_, _, isParam := typedParamTag(field)
+		if isParam {
+			// bound from path, query or header by Typed
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				g.addStructProperties(fieldType, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.schema(field.Type)
+		if field.Type.Kind() != reflect.Ptr && !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package ripo
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ilius/is/v2"
+)
+
+type openAPITestAddress struct {
+	City string `json:"city"`
+}
+
+type openAPITestUser struct {
+	ID        int                 `json:"id"`
+	Name      string              `json:"name"`
+	Email     string              `json:"email,omitempty"`
+	Tags      []string            `json:"tags"`
+	Address   *openAPITestAddress `json:"address"`
+	Friends   []*openAPITestUser  `json:"friends,omitempty"`
+	CreatedAt time.Time           `json:"createdAt"`
+	Secret    string              `json:"-"`
+	internal  int
+}
+
+func openAPITestGetUser(req Request) (*Response, error) {
+	return &Response{Data: &openAPITestUser{}}, nil
+}
+
+func openAPITestDocument(t *testing.T) map[string]any {
+	router := NewRouter()
+	router.Handle("GET", "/users/{id}", openAPITestGetUser).
+		Summary("Get user").
+		Tags("users").
+		Params(
+			&Param{Name: "id", In: ParamInPath, Type: reflect.TypeOf(0), Description: "user id"},
+			&Param{Name: "fields", In: ParamInQuery, Type: reflect.TypeOf([]string{})},
+		).
+		Returns(&openAPITestUser{}).
+		Errors(NotFound, PermissionDenied)
+	router.Handle("POST", "/users", func(req Request) (*Response, error) {
+		return nil, nil
+	}).Params(
+		&Param{Name: "name", In: ParamInBody, Required: true},
+		&Param{Name: "X-Request-Source", In: ParamInHeader},
+	)
+	router.Handle("GET", "/openapi.json", router.OpenAPIHandler(&OpenAPIInfo{
+		Title:   "Test",
+		Version: "1.0",
+	})).OperationID("openapi")
+	w := doTestRequest(router, "GET", "/openapi.json", nil, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%v, body=%v", w.Code, w.Body.String())
+	}
+	doc := map[string]any{}
+	err := json.Unmarshal(w.Body.Bytes(), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func jsonOf(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
+
+func TestOpenAPI(t *testing.T) {
+	is := is.New(t)
+	doc := openAPITestDocument(t)
+	is.Equal("3.1.0", doc["openapi"])
+	is.Equal(`{"title":"Test","version":"1.0"}`, jsonOf(doc["info"]))
+	paths := doc["paths"].(map[string]any)
+	is.Equal(3, len(paths))
+	getUser := paths["/users/{id}"].(map[string]any)["get"].(map[string]any)
+	is.Equal("openAPITestGetUser", getUser["operationId"])
+	is.Equal("Get user", getUser["summary"])
+	is.Equal(`["users"]`, jsonOf(getUser["tags"]))
+	is.Equal(
+		`[{"description":"user id","in":"path","name":"id","required":true,"schema":{"format":"int64","type":"integer"}},`+
+			`{"in":"query","name":"fields","required":false,"schema":{"items":{"type":"string"},"type":"array"}}]`,
+		jsonOf(getUser["parameters"]),
+	)
+	responses := getUser["responses"].(map[string]any)
+	is.Equal(
+		`{"application/json":{"schema":{"$ref":"#/components/schemas/github.com_ilius_ripo.openAPITestUser"}}}`,
+		jsonOf(responses["200"].(map[string]any)["content"]),
+	)
+	is.Equal("Not Found: NotFound", responses["404"].(map[string]any)["description"])
+	is.Equal("Forbidden: PermissionDenied", responses["403"].(map[string]any)["description"])
+	is.Equal("Bad Request: InvalidArgument, MissingArgument", responses["400"].(map[string]any)["description"])
+	is.Equal("Internal Server Error: Internal", responses["500"].(map[string]any)["description"])
+	is.Equal(
+		`{"application/json":{"schema":{"$ref":"#/components/schemas/Error"}}}`,
+		jsonOf(responses["500"].(map[string]any)["content"]),
+	)
+
+	postUsers := paths["/users"].(map[string]any)["post"].(map[string]any)
+	_, hasOperationID := postUsers["operationId"]
+	is.False(hasOperationID)
+	is.Equal(
+		`{"content":{"application/json":{"schema":{"properties":{"name":{"type":"string"}},"required":["name"],"type":"object"}}},"required":true}`,
+		jsonOf(postUsers["requestBody"]),
+	)
+	is.Equal(
+		`[{"in":"header","name":"X-Request-Source","required":false,"schema":{"type":"string"}}]`,
+		jsonOf(postUsers["parameters"]),
+	)
+
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+	is.Equal(
+		`{"properties":{"address":{"$ref":"#/components/schemas/github.com_ilius_ripo.openAPITestAddress"},`+
+			`"createdAt":{"format":"date-time","type":"string"},"email":{"type":"string"},`+
+			`"friends":{"items":{"$ref":"#/components/schemas/github.com_ilius_ripo.openAPITestUser"},"type":"array"},`+
+			`"id":{"format":"int64","type":"integer"},"name":{"type":"string"},`+
+			`"tags":{"items":{"type":"string"},"type":"array"}},`+
+			`"required":["id","name","tags","createdAt"],"type":"object"}`,
+		jsonOf(schemas["github.com_ilius_ripo.openAPITestUser"]),
+	)
+	is.Equal(
+		`{"properties":{"city":{"type":"string"}},"required":["city"],"type":"object"}`,
+		jsonOf(schemas["github.com_ilius_ripo.openAPITestAddress"]),
+	)
+	is.Equal(`["code","error"]`, jsonOf(schemas["Error"].(map[string]any)["required"]))
+	errorProperties := schemas["Error"].(map[string]any)["properties"].(map[string]any)
+	is.Equal(`{"type":"string"}`, jsonOf(errorProperties["requestId"]))
+	is.Equal(`{"type":"object"}`, jsonOf(errorProperties["details"]))
+}
+
+type openAPITestPage[T any] struct {
+	Items []T `json:"items"`
+}
+
+// Cookie: same name as http.Cookie
+type Cookie struct {
+	Value string `json:"value"`
+}
+
+func TestOpenAPI_SchemaNames(t *testing.T) {
+	is := is.New(t)
+	g := &openAPIGenerator{
+		schemas:     map[string]any{},
+		schemaNames: map[reflect.Type]string{},
+	}
+	is.Equal(
+		`{"$ref":"#/components/schemas/github.com_ilius_ripo.openAPITestPage_github.com_ilius_ripo.openAPITestUser"}`,
+		jsonOf(g.schema(reflect.TypeOf(openAPITestPage[openAPITestUser]{}))),
+	)
+	is.Equal(
+		`{"$ref":"#/components/schemas/github.com_ilius_ripo.Cookie"}`,
+		jsonOf(g.schema(reflect.TypeOf(&Cookie{}))),
+	)
+	is.Equal(
+		`{"$ref":"#/components/schemas/net_http.Cookie"}`,
+		jsonOf(g.schema(reflect.TypeOf(http.Cookie{}))),
+	)
+	// same type again
+	is.Equal(
+		`{"$ref":"#/components/schemas/github.com_ilius_ripo.Cookie"}`,
+		jsonOf(g.schema(reflect.TypeOf(Cookie{}))),
+	)
+	is.Equal(`{"properties":{"value":{"type":"string"}},"required":["value"],"type":"object"}`, jsonOf(g.schemas["github.com_ilius_ripo.Cookie"]))
+	is.Equal(5, len(g.schemas))
+	// sanitized names of different types are unique
+	type X struct{}
+	g.schemas["github.com_ilius_ripo.X"] = map[string]any{}
+	is.Equal("github.com_ilius_ripo.X_2", g.schemaName(reflect.TypeOf(X{})))
+}
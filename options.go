@@ -0,0 +1,165 @@
+package ripo
+
+import (
+	"log/slog"
+	"time"
+)
+
+// HandlerOption: per-handler option, passed to TranslateHandler
+type HandlerOption func(config *handlerConfig)
+
+type handlerConfig struct {
+	handlerName string // empty means name of handler function
+
+	maxBodySize int64 // 0 means use global maxBodySize, negative means no limit
+
+	disableCompression bool
+
+	sseHeartbeatInterval time.Duration // 0 means use global sseHeartbeatInterval, negative means disabled
+
+	autoETag *bool // nil means use global autoETag
+
+	batchConcurrency int // 0 means sequential
+	maxBatchSize     int // 0 means defaultMaxBatchSize
+
+	logger *slog.Logger // nil means global logger
+
+	accessLogger     *AccessLogger // nil means global accessLogger
+	disableAccessLog bool
+
+	metrics        *Metrics // nil means global metrics
+	disableMetrics bool
+
+	spanExporter   SpanExporter // nil means global spanExporter
+	disableTracing bool
+
+	rateLimiters []*RateLimiter
+
+	concurrencyLimiter            *ConcurrencyLimiter
+	disableGlobalConcurrencyLimit bool
+}
+
+func newHandlerConfig(options []HandlerOption) *handlerConfig {
+	config := &handlerConfig{}
+	for _, option := range options {
+		option(config)
+	}
+	return config
+}
+
+// HandlerName: set name of handler that is used in logs, metrics, tracing spans and
+// tracebacks, default is the name of handler function
+func HandlerName(name string) HandlerOption {
+	return func(config *handlerConfig) {
+		config.handlerName = name
+	}
+}
+
+func (config *handlerConfig) getHandlerName(handler any) string {
+	if config.handlerName != "" {
+		return config.handlerName
+	}
+	handlerTyped, isHandler := handler.(Handler)
+	if isHandler {
+		return handlerFunctionName(handlerTyped)
+	}
+	return getFunctionName(handler)
+}
+
+// maxBodySize: global maximum size of request body in bytes, 0 means no limit
+var maxBodySize int64
+
+// SetMaxBodySize: set global maximum size of request body in bytes, 0 means no limit
+// Can be overridden per handler with MaxBodySize option
+func SetMaxBodySize(size int64) {
+	if size < 0 {
+		panic("SetMaxBodySize: negative size")
+	}
+	maxBodySize = size
+}
+
+// MaxBodySize: set maximum size of request body in bytes for this handler,
+// overriding global SetMaxBodySize, negative value means no limit
+func MaxBodySize(size int64) HandlerOption {
+	return func(config *handlerConfig) {
+		config.maxBodySize = size
+	}
+}
+
+func (config *handlerConfig) getMaxBodySize() int64 {
+	if config.maxBodySize == 0 {
+		return maxBodySize
+	}
+	if config.maxBodySize < 0 {
+		return 0
+	}
+	return config.maxBodySize
+}
+
+// DisableCompression: never compress response body of this handler
+func DisableCompression() HandlerOption {
+	return func(config *handlerConfig) {
+		config.disableCompression = true
+	}
+}
+
+// SSEHeartbeatInterval: set interval of heartbeat comments for this SSE handler,
+// overriding global SetSSEHeartbeatInterval, negative value disables heartbeats
+func SSEHeartbeatInterval(interval time.Duration) HandlerOption {
+	return func(config *handlerConfig) {
+		config.sseHeartbeatInterval = interval
+	}
+}
+
+func (config *handlerConfig) getSSEHeartbeatInterval() time.Duration {
+	if config.sseHeartbeatInterval == 0 {
+		return sseHeartbeatInterval
+	}
+	if config.sseHeartbeatInterval < 0 {
+		return 0
+	}
+	return config.sseHeartbeatInterval
+}
+
+// AutoETag: enable or disable automatic ETag for this handler, overriding global SetAutoETag
+func AutoETag(enabled bool) HandlerOption {
+	return func(config *handlerConfig) {
+		config.autoETag = &enabled
+	}
+}
+
+func (config *handlerConfig) getAutoETag() bool {
+	if config.autoETag == nil {
+		return autoETag
+	}
+	return *config.autoETag
+}
+
+// BatchConcurrency: maximum number of sub-requests of BatchHandler that are executed in parallel
+// default is 1, meaning sub-requests are executed sequentially, in the given order
+func BatchConcurrency(n int) HandlerOption {
+	return func(config *handlerConfig) {
+		config.batchConcurrency = n
+	}
+}
+
+func (config *handlerConfig) getBatchConcurrency() int {
+	if config.batchConcurrency < 1 {
+		return 1
+	}
+	return config.batchConcurrency
+}
+
+// MaxBatchSize: maximum number of sub-requests in a request of BatchHandler, default is 50
+func MaxBatchSize(n int) HandlerOption {
+	return func(config *handlerConfig) {
+		config.maxBatchSize = n
+	}
+}
+
+func (config *handlerConfig) getMaxBatchSize() int {
+	if config.maxBatchSize < 1 {
+		return defaultMaxBatchSize
+	}
+	return config.maxBatchSize
+}
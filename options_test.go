@@ -0,0 +1,146 @@
+package ripo
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+func bodyLengthHandler(req Request) (*Response, error) {
+	body, err := req.Body()
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Data: map[string]int{"length": len(body)},
+	}, nil
+}
+
+func TestMaxBodySize_Handler(t *testing.T) {
+	is := is.New(t)
+	handlerFunc := TranslateHandler(bodyLengthHandler, MaxBodySize(10))
+	{
+		r, err := http.NewRequest("POST", "", strings.NewReader(`{"a": 1}`))
+		is.NotErr(err)
+		w := httptest.NewRecorder()
+		handlerFunc(w, r)
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("{\"length\":8}", w.Body.String())
+	}
+	{
+		r, err := http.NewRequest("POST", "", strings.NewReader(`{"a": 1234567890}`))
+		is.NotErr(err)
+		w := httptest.NewRecorder()
+		handlerFunc(w, r)
+		is.Equal(http.StatusRequestEntityTooLarge, w.Code)
+		is.Equal(
+			"{\"code\":\"PayloadTooLarge\",\"error\":\"request body is too large, must be at most 10 bytes\"}",
+			w.Body.String(),
+		)
+	}
+}
+
+func TestMaxBodySize_Global(t *testing.T) {
+	is := is.New(t)
+	SetMaxBodySize(5)
+	defer SetMaxBodySize(0)
+	{
+		r, err := http.NewRequest("POST", "", strings.NewReader(`{"a": 1}`))
+		is.NotErr(err)
+		w := httptest.NewRecorder()
+		TranslateHandler(bodyLengthHandler)(w, r)
+		is.Equal(http.StatusRequestEntityTooLarge, w.Code)
+	}
+	{
+		// per-handler option overrides global limit
+		r, err := http.NewRequest("POST", "", strings.NewReader(`{"a": 1}`))
+		is.NotErr(err)
+		w := httptest.NewRecorder()
+		TranslateHandler(bodyLengthHandler, MaxBodySize(-1))(w, r)
+		is.Equal(http.StatusOK, w.Code)
+	}
+	is.ShouldPanic(func() {
+		SetMaxBodySize(-1)
+	})
+}
+
+func TestMaxBodySize_Details(t *testing.T) {
+	is := is.New(t)
+	{
+		r, err := http.NewRequest("POST", "", strings.NewReader("0123456789"))
+		is.NotErr(err)
+		req := &requestImp{r: r, handlerName: "Test", maxBodySize: 4}
+		_, err = req.Body()
+		AssertError(t, err, PayloadTooLarge, "request body is too large, must be at most 4 bytes")
+		details := err.(RPCError).Details()
+		is.Equal(int64(4), details["maxBodySize"])
+		is.Equal(int64(10), details["bodySize"]) // from Content-Length
+		is.Equal(uint32(ResourceExhausted), err.(RPCError).GrpcCode())
+		// error is cached
+		_, err2 := req.Body()
+		is.Equal(err, err2)
+	}
+	{
+		// unknown Content-Length, stops reading after the limit
+		r, err := http.NewRequest("POST", "", io.NopCloser(strings.NewReader("0123456789")))
+		is.NotErr(err)
+		is.Equal(int64(0), r.ContentLength)
+		req := &requestImp{r: r, handlerName: "Test", maxBodySize: 4}
+		_, err = req.Body()
+		AssertError(t, err, PayloadTooLarge, "request body is too large, must be at most 4 bytes")
+		is.Equal(int64(5), err.(RPCError).Details()["bodySize"])
+	}
+}
+
+func TestMaxBodySize_Form(t *testing.T) {
+	is := is.New(t)
+	newFormRequest := func(body string) *http.Request {
+		r, err := http.NewRequest("POST", "", strings.NewReader(body))
+		is.NotErr(err)
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+	handlerFunc := TranslateHandler(func(req Request) (*Response, error) {
+		name, err := req.GetString("name", FromForm)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{Data: *name}, nil
+	}, MaxBodySize(10))
+	{
+		w := httptest.NewRecorder()
+		handlerFunc(w, newFormRequest("name=John"))
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("John", w.Body.String())
+	}
+	{
+		w := httptest.NewRecorder()
+		handlerFunc(w, newFormRequest("name="+strings.Repeat("a", 5000)))
+		is.Equal(http.StatusRequestEntityTooLarge, w.Code)
+		is.Equal(
+			"{\"code\":\"PayloadTooLarge\",\"error\":\"request body is too large, must be at most 10 bytes\"}",
+			w.Body.String(),
+		)
+	}
+	{
+		w := httptest.NewRecorder()
+		TranslateSSEHandler(func(req Request, stream EventStream) error {
+			return nil
+		}, MaxBodySize(10))(w, newFormRequest("name="+strings.Repeat("a", 5000)))
+		is.Equal(http.StatusRequestEntityTooLarge, w.Code)
+	}
+	{
+		// unknown Content-Length
+		r := newFormRequest("")
+		r.Body = io.NopCloser(strings.NewReader("name=" + strings.Repeat("a", 5000)))
+		r.ContentLength = 0
+		req := &requestImp{r: r, maxBodySize: 10}
+		err := req.parseForm(httptest.NewRecorder())
+		AssertError(t, err, PayloadTooLarge, "request body is too large, must be at most 10 bytes")
+		is.Equal(int64(11), err.(RPCError).Details()["bodySize"])
+	}
+}
@@ -0,0 +1,249 @@
+package ripo
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	paginationDefaultLimit = 20
+	paginationMaxLimit     = 100
+	paginationCursorKey    = randomCursorKey()
+)
+
+func randomCursorKey() []byte {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// SetPaginationLimits: set default and maximum value of page size (limit) for req.Pagination()
+func SetPaginationLimits(defaultLimit int, maxLimit int) {
+	if defaultLimit < 1 {
+		panic("SetPaginationLimits: defaultLimit must be positive")
+	}
+	if maxLimit < defaultLimit {
+		panic("SetPaginationLimits: maxLimit is less than defaultLimit")
+	}
+	paginationDefaultLimit = defaultLimit
+	paginationMaxLimit = maxLimit
+}
+
+// SetPaginationCursorKey: set the secret key used to sign cursors in EncodeCursor
+// a random key is generated on startup, which means cursors are invalidated after restart
+// and are not shared between multiple instances of the server, so set it if that matters
+func SetPaginationCursorKey(key []byte) {
+	if len(key) == 0 {
+		panic("SetPaginationCursorKey: empty key")
+	}
+	paginationCursorKey = key
+}
+
+// Pagination: parsed pagination parameters of request, returned by req.Pagination()
+type Pagination struct {
+	Limit  int
+	Offset int
+
+	// Cursor: decoded value of cursor (that was passed to EncodeCursor), empty if not given
+	// Offset is zero if Cursor is given
+	Cursor string
+}
+
+func cursorSignature(value []byte) []byte {
+	mac := hmac.New(sha256.New, paginationCursorKey)
+	mac.Write(value)
+	return mac.Sum(nil)[:16]
+}
+
+// EncodeCursor: returns an opaque signed cursor for given value (for example last seen id)
+// to be used as Page.NextCursor or Page.PrevCursor, client can not forge or modify it
+func EncodeCursor(value string) string {
+	encoding := base64.RawURLEncoding
+	return encoding.EncodeToString([]byte(value)) + "." + encoding.EncodeToString(cursorSignature([]byte(value)))
+}
+
+// DecodeCursor: verifies and decodes a cursor created by EncodeCursor
+func DecodeCursor(cursor string) (string, error) {
+	invalidErr := NewError(InvalidArgument, "invalid 'cursor'", nil).Add("cursor", cursor)
+	parts := strings.Split(cursor, ".")
+	if len(parts) != 2 {
+		return "", invalidErr
+	}
+	encoding := base64.RawURLEncoding
+	value, err := encoding.DecodeString(parts[0])
+	if err != nil {
+		return "", invalidErr
+	}
+	signature, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return "", invalidErr
+	}
+	if !hmac.Equal(signature, cursorSignature(value)) {
+		return "", invalidErr
+	}
+	return string(value), nil
+}
+
+func (req *requestImp) Pagination() (*Pagination, error) {
+	limit, err := FromForm.GetInt(req, "limit")
+	if err != nil {
+		return nil, err
+	}
+	if limit == nil {
+		limit, err = FromForm.GetInt(req, "pageSize")
+		if err != nil {
+			return nil, err
+		}
+	}
+	p := &Pagination{Limit: paginationDefaultLimit}
+	if limit != nil {
+		if *limit < 1 {
+			return nil, NewError(InvalidArgument, "'limit' must be positive", nil).Add("limit", *limit)
+		}
+		if *limit > paginationMaxLimit {
+			return nil, NewError(
+				InvalidArgument,
+				fmt.Sprintf("'limit' must be at most %d", paginationMaxLimit),
+				nil,
+			).Add("limit", *limit)
+		}
+		p.Limit = *limit
+	}
+	offset, err := FromForm.GetInt(req, "offset")
+	if err != nil {
+		return nil, err
+	}
+	page, err := FromForm.GetInt(req, "page")
+	if err != nil {
+		return nil, err
+	}
+	cursor := req.GetFormValue("cursor")
+	if cursor != "" {
+		if offset != nil || page != nil {
+			return nil, NewError(InvalidArgument, "'cursor' can not be combined with 'offset' or 'page'", nil)
+		}
+		p.Cursor, err = DecodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+	if offset != nil && page != nil {
+		return nil, NewError(InvalidArgument, "'offset' can not be combined with 'page'", nil)
+	}
+	if offset != nil {
+		if *offset < 0 {
+			return nil, NewError(InvalidArgument, "'offset' must not be negative", nil).Add("offset", *offset)
+		}
+		p.Offset = *offset
+	}
+	if page != nil {
+		if *page < 1 {
+			return nil, NewError(InvalidArgument, "'page' must be positive", nil).Add("page", *page)
+		}
+		if *page > math.MaxInt/p.Limit {
+			return nil, NewError(InvalidArgument, "'page' is too large", nil).Add("page", *page)
+		}
+		p.Offset = (*page - 1) * p.Limit
+	}
+	return p, nil
+}
+
+// Page: a page of items, to be passed to PaginatedResponse
+type Page struct {
+	Items any
+
+	// Total: total number of items, optional
+	Total *int
+
+	// HasMore: there are more items after this page
+	// no need to set if Total is given, or NextCursor is used
+	HasMore bool
+
+	// NextCursor, PrevCursor: returned by EncodeCursor, for cursor-based pagination
+	NextCursor string
+	PrevCursor string
+}
+
+type paginationInfo struct {
+	Limit      int    `json:"limit" xml:"limit"`
+	Offset     int    `json:"offset" xml:"offset"`
+	Total      *int   `json:"total,omitempty" xml:"total,omitempty"`
+	HasMore    bool   `json:"hasMore" xml:"hasMore"`
+	NextCursor string `json:"nextCursor,omitempty" xml:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty" xml:"prevCursor,omitempty"`
+}
+
+type paginatedBody struct {
+	XMLName    xml.Name        `json:"-" xml:"page"`
+	Items      any             `json:"items" xml:"items"`
+	Pagination *paginationInfo `json:"pagination" xml:"pagination"`
+}
+
+// PaginatedResponse: response with envelope {"items": [...], "pagination": {...}}
+// and Link header (RFC 8288) with rel="next" and rel="prev" links, based on request URL
+// p is the value returned by req.Pagination()
+func PaginatedResponse(req Request, p *Pagination, page *Page) *Response {
+	info := &paginationInfo{
+		Limit:      p.Limit,
+		Offset:     p.Offset,
+		Total:      page.Total,
+		HasMore:    page.HasMore || page.NextCursor != "",
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	}
+	if page.Total != nil && p.Cursor == "" && p.Offset+p.Limit < *page.Total {
+		info.HasMore = true
+	}
+	links := []string{}
+	addLink := func(rel string, params map[string]string) {
+		u := req.URL()
+		query := u.Query()
+		for _, key := range []string{"offset", "page", "cursor", "pageSize"} {
+			query.Del(key)
+		}
+		query.Set("limit", strconv.Itoa(p.Limit))
+		for key, value := range params {
+			query.Set(key, value)
+		}
+		u.RawQuery = query.Encode()
+		links = append(links, fmt.Sprintf("<%s>; rel=%q", u.RequestURI(), rel))
+	}
+	switch {
+	case page.NextCursor != "":
+		addLink("next", map[string]string{"cursor": page.NextCursor})
+	case info.HasMore && p.Cursor == "":
+		addLink("next", map[string]string{"offset": strconv.Itoa(p.Offset + p.Limit)})
+	}
+	switch {
+	case page.PrevCursor != "":
+		addLink("prev", map[string]string{"cursor": page.PrevCursor})
+	case p.Offset > 0:
+		prevOffset := p.Offset - p.Limit
+		if prevOffset < 0 {
+			prevOffset = 0
+		}
+		addLink("prev", map[string]string{"offset": strconv.Itoa(prevOffset)})
+	}
+	res := &Response{
+		Data: &paginatedBody{
+			Items:      page.Items,
+			Pagination: info,
+		},
+	}
+	if len(links) > 0 {
+		res.Header = http.Header{"Link": []string{strings.Join(links, ", ")}}
+	}
+	return res
+}
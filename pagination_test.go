@@ -0,0 +1,168 @@
+package ripo
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+var paginationTestItems = []int{1, 2, 3, 4, 5, 6, 7}
+
+func paginationOffsetHandler(req Request) (*Response, error) {
+	p, err := req.Pagination()
+	if err != nil {
+		return nil, err
+	}
+	items := []int{}
+	for i := p.Offset; i < len(paginationTestItems) && i < p.Offset+p.Limit; i++ {
+		items = append(items, paginationTestItems[i])
+	}
+	total := len(paginationTestItems)
+	return PaginatedResponse(req, p, &Page{
+		Items: items,
+		Total: &total,
+	}), nil
+}
+
+func paginationCursorHandler(req Request) (*Response, error) {
+	p, err := req.Pagination()
+	if err != nil {
+		return nil, err
+	}
+	start := 0
+	if p.Cursor != "" {
+		start, err = strconv.Atoi(p.Cursor)
+		if err != nil {
+			return nil, NewError(Internal, "", err)
+		}
+	}
+	items := []int{}
+	for i := start; i < len(paginationTestItems) && i < start+p.Limit; i++ {
+		items = append(items, paginationTestItems[i])
+	}
+	page := &Page{Items: items}
+	if start+p.Limit < len(paginationTestItems) {
+		page.NextCursor = EncodeCursor(strconv.Itoa(start + p.Limit))
+	}
+	return PaginatedResponse(req, p, page), nil
+}
+
+func TestPagination_Offset(t *testing.T) {
+	is := is.New(t)
+	{
+		w := doTestRequest(paginationOffsetHandler, "GET", "/items?limit=3&sort=id", nil, "")
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal(
+			`{"items":[1,2,3],"pagination":{"limit":3,"offset":0,"total":7,"hasMore":true}}`,
+			w.Body.String(),
+		)
+		is.Equal(`</items?limit=3&offset=3&sort=id>; rel="next"`, w.Header().Get("Link"))
+	}
+	{
+		w := doTestRequest(paginationOffsetHandler, "GET", "/items?pageSize=3&page=2", nil, "")
+		is.Equal(
+			`{"items":[4,5,6],"pagination":{"limit":3,"offset":3,"total":7,"hasMore":true}}`,
+			w.Body.String(),
+		)
+		is.Equal(
+			`</items?limit=3&offset=6>; rel="next", </items?limit=3&offset=0>; rel="prev"`,
+			w.Header().Get("Link"),
+		)
+	}
+	{
+		w := doTestRequest(paginationOffsetHandler, "GET", "/items?limit=3&offset=5", nil, "")
+		is.Equal(
+			`{"items":[6,7],"pagination":{"limit":3,"offset":5,"total":7,"hasMore":false}}`,
+			w.Body.String(),
+		)
+		is.Equal(`</items?limit=3&offset=2>; rel="prev"`, w.Header().Get("Link"))
+	}
+	{
+		w := doTestRequest(paginationOffsetHandler, "GET", "/items", nil, "")
+		is.Equal(
+			`{"items":[1,2,3,4,5,6,7],"pagination":{"limit":20,"offset":0,"total":7,"hasMore":false}}`,
+			w.Body.String(),
+		)
+		is.Equal("", w.Header().Get("Link"))
+	}
+}
+
+func TestPagination_Cursor(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(paginationCursorHandler, "GET", "/items?limit=4", nil, "")
+	is.Equal(http.StatusOK, w.Code)
+	cursor := EncodeCursor("4")
+	is.Equal(
+		`{"items":[1,2,3,4],"pagination":{"limit":4,"offset":0,"hasMore":true,"nextCursor":"`+cursor+`"}}`,
+		w.Body.String(),
+	)
+	is.Equal(`</items?cursor=`+cursor+`&limit=4>; rel="next"`, w.Header().Get("Link"))
+
+	w = doTestRequest(paginationCursorHandler, "GET", "/items?limit=4&cursor="+cursor, nil, "")
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal(
+		`{"items":[5,6,7],"pagination":{"limit":4,"offset":0,"hasMore":false}}`,
+		w.Body.String(),
+	)
+	is.Equal("", w.Header().Get("Link"))
+}
+
+func TestPagination_Invalid(t *testing.T) {
+	is := is.New(t)
+	forged := EncodeCursor("4")
+	forged = "NQ" + forged[2:]
+	for url, expectedBody := range map[string]string{
+		"/items?limit=0":   `{"code":"InvalidArgument","error":"'limit' must be positive"}`,
+		"/items?limit=101": `{"code":"InvalidArgument","error":"'limit' must be at most 100"}`,
+		"/items?limit=x":   `{"code":"InvalidArgument","error":"invalid 'limit', must be integer"}`,
+		"/items?offset=-1": `{"code":"InvalidArgument","error":"'offset' must not be negative"}`,
+		"/items?page=0":    `{"code":"InvalidArgument","error":"'page' must be positive"}`,
+		"/items?page=" + strconv.Itoa(math.MaxInt): `{"code":"InvalidArgument","error":"'page' is too large"}`,
+		"/items?page=1&offset=2":                   `{"code":"InvalidArgument","error":"'offset' can not be combined with 'page'"}`,
+		"/items?cursor=abc&offset=2":               `{"code":"InvalidArgument","error":"'cursor' can not be combined with 'offset' or 'page'"}`,
+		"/items?cursor=abc":                        `{"code":"InvalidArgument","error":"invalid 'cursor'"}`,
+		"/items?cursor=" + forged:                  `{"code":"InvalidArgument","error":"invalid 'cursor'"}`,
+		"/items?cursor=" + forged + "x":            `{"code":"InvalidArgument","error":"invalid 'cursor'"}`,
+	} {
+		w := doTestRequest(paginationCursorHandler, "GET", url, nil, "")
+		is.Msg("url=%v", url).Equal(http.StatusBadRequest, w.Code)
+		is.Msg("url=%v", url).Equal(expectedBody, w.Body.String())
+	}
+}
+
+func TestPaginationCursorKey(t *testing.T) {
+	is := is.New(t)
+	defer SetPaginationCursorKey(paginationCursorKey)
+	cursor := EncodeCursor("abc")
+	value, err := DecodeCursor(cursor)
+	is.NotErr(err)
+	is.Equal("abc", value)
+	SetPaginationCursorKey([]byte("secret"))
+	_, err = DecodeCursor(cursor)
+	AssertError(t, err, InvalidArgument, "invalid 'cursor'")
+	is.ShouldPanic(func() {
+		SetPaginationCursorKey(nil)
+	})
+}
+
+func TestSetPaginationLimits(t *testing.T) {
+	is := is.New(t)
+	defer SetPaginationLimits(paginationDefaultLimit, paginationMaxLimit)
+	SetPaginationLimits(2, 5)
+	w := doTestRequest(paginationOffsetHandler, "GET", "/items", nil, "")
+	is.Equal(
+		`{"items":[1,2],"pagination":{"limit":2,"offset":0,"total":7,"hasMore":true}}`,
+		w.Body.String(),
+	)
+	w = doTestRequest(paginationOffsetHandler, "GET", "/items?limit=6", nil, "")
+	is.Equal(http.StatusBadRequest, w.Code)
+	is.ShouldPanic(func() {
+		SetPaginationLimits(0, 5)
+	})
+	is.ShouldPanic(func() {
+		SetPaginationLimits(5, 2)
+	})
+}
@@ -0,0 +1,369 @@
+package ripo
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitResult: result of taking a request from quota of a key
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until quota is fully available again
+	RetryAfter time.Duration // until next request is allowed, 0 if this request is allowed
+}
+
+// RateLimitAlgorithm: decides whether a request is allowed, based on state of its key
+// state is nil for a new (or expired) key, and it's encoded as bytes to be kept in any RateLimitStore
+type RateLimitAlgorithm interface {
+	Take(state []byte, now time.Time) ([]byte, *RateLimitResult)
+
+	// TTL: how long state of an idle key must be kept
+	TTL() time.Duration
+
+	// Policy: value of RateLimit-Policy header, like "10;w=60"
+	Policy() string
+}
+
+// RateLimitStore: keeps state of keys of RateLimiter, implement it for external stores
+type RateLimitStore interface {
+	// Update: atomically replaces state of key with the state returned by update
+	// state given to update is nil if key does not exist or is expired
+	Update(ctx context.Context, key string, ttl time.Duration, update func(state []byte) []byte) error
+}
+
+// RateLimitKeyFunc: returns the key that requests are limited by, empty key means not limited
+type RateLimitKeyFunc func(req Request) (string, error)
+
+// RateLimitByRemoteIP: limits requests by remote IP address of client
+func RateLimitByRemoteIP(req Request) (string, error) {
+	return req.RemoteIP()
+}
+
+// RateLimitByHeader: limits requests by value of header, requests without header are not limited
+func RateLimitByHeader(name string) RateLimitKeyFunc {
+	return func(req Request) (string, error) {
+		return req.Header(name), nil
+	}
+}
+
+// RateLimitByUser: limits requests by authenticated user, that is set in request context
+// with given key (for example by an authentication middleware), unauthenticated requests are not limited
+func RateLimitByUser(contextKey any) RateLimitKeyFunc {
+	return func(req Request) (string, error) {
+		value := req.Context().Value(contextKey)
+		if value == nil {
+			return "", nil
+		}
+		user, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("ctx.Value(%#v) = %#v, must be string", contextKey, value)
+		}
+		return user, nil
+	}
+}
+
+// tokenBucket: bucket of size limit, refilled with rate of limit per period
+type tokenBucket struct {
+	limit  int
+	period time.Duration
+}
+
+// TokenBucket: allows bursts of up to limit requests, with an average of limit requests per period
+func TokenBucket(limit int, period time.Duration) RateLimitAlgorithm {
+	if limit < 1 {
+		panic("TokenBucket: limit must be positive")
+	}
+	if period <= 0 {
+		panic("TokenBucket: period must be positive")
+	}
+	return &tokenBucket{
+		limit:  limit,
+		period: period,
+	}
+}
+
+func (tb *tokenBucket) TTL() time.Duration {
+	return tb.period
+}
+
+func (tb *tokenBucket) Policy() string {
+	return fmt.Sprintf("%d;w=%s", tb.limit, durationSeconds(tb.period))
+}
+
+// Take: state is number of tokens (float64 bits) and time of last update (unix nanoseconds)
+func (tb *tokenBucket) Take(state []byte, now time.Time) ([]byte, *RateLimitResult) {
+	tokens := float64(tb.limit)
+	if len(state) == 16 {
+		tokens = math.Float64frombits(binary.BigEndian.Uint64(state[:8]))
+		last := time.Unix(0, int64(binary.BigEndian.Uint64(state[8:])))
+		elapsed := now.Sub(last)
+		if elapsed > 0 {
+			tokens += elapsed.Seconds() * float64(tb.limit) / tb.period.Seconds()
+			if tokens > float64(tb.limit) {
+				tokens = float64(tb.limit)
+			}
+		}
+	}
+	perToken := time.Duration(float64(tb.period) / float64(tb.limit))
+	result := &RateLimitResult{
+		Limit: tb.limit,
+	}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - tokens) * float64(perToken))
+	}
+	result.Remaining = int(tokens)
+	result.Reset = time.Duration((float64(tb.limit) - tokens) * float64(perToken))
+	newState := make([]byte, 16)
+	binary.BigEndian.PutUint64(newState[:8], math.Float64bits(tokens))
+	binary.BigEndian.PutUint64(newState[8:], uint64(now.UnixNano()))
+	return newState, result
+}
+
+// slidingWindow: sliding window counter, estimates number of requests in last window
+// by weighting count of previous fixed window
+type slidingWindow struct {
+	limit  int
+	window time.Duration
+}
+
+// SlidingWindow: allows up to limit requests in any window of time
+func SlidingWindow(limit int, window time.Duration) RateLimitAlgorithm {
+	if limit < 1 {
+		panic("SlidingWindow: limit must be positive")
+	}
+	if window <= 0 {
+		panic("SlidingWindow: window must be positive")
+	}
+	return &slidingWindow{
+		limit:  limit,
+		window: window,
+	}
+}
+
+func (sw *slidingWindow) TTL() time.Duration {
+	return 2 * sw.window
+}
+
+func (sw *slidingWindow) Policy() string {
+	return fmt.Sprintf("%d;w=%s", sw.limit, durationSeconds(sw.window))
+}
+
+// Take: state is start of current fixed window (unix nanoseconds), and counts of
+// previous and current fixed windows
+func (sw *slidingWindow) Take(state []byte, now time.Time) ([]byte, *RateLimitResult) {
+	windowStart := now.Truncate(sw.window)
+	var prevCount, currCount int64
+	if len(state) == 24 {
+		stateStart := time.Unix(0, int64(binary.BigEndian.Uint64(state[:8])))
+		switch {
+		case stateStart.Equal(windowStart):
+			prevCount = int64(binary.BigEndian.Uint64(state[8:16]))
+			currCount = int64(binary.BigEndian.Uint64(state[16:]))
+		case stateStart.Equal(windowStart.Add(-sw.window)):
+			prevCount = int64(binary.BigEndian.Uint64(state[16:]))
+		}
+	}
+	elapsed := now.Sub(windowStart)
+	prevWeight := 1 - float64(elapsed)/float64(sw.window)
+	estimate := float64(prevCount)*prevWeight + float64(currCount)
+	result := &RateLimitResult{
+		Limit: sw.limit,
+	}
+	if estimate+1 <= float64(sw.limit) {
+		currCount++
+		estimate++
+		result.Allowed = true
+	} else if currCount < int64(sw.limit) {
+		// wait until enough of previous window is out of sliding window
+		until := float64(sw.window) * (1 - float64(int64(sw.limit)-1-currCount)/float64(prevCount))
+		result.RetryAfter = time.Duration(until) - elapsed
+	} else {
+		// wait for next window, and until enough of current window is out of sliding window
+		until := float64(sw.window) * (1 - float64(sw.limit-1)/float64(currCount))
+		result.RetryAfter = sw.window - elapsed + time.Duration(until)
+	}
+	if !result.Allowed && result.RetryAfter <= 0 {
+		result.RetryAfter = time.Nanosecond
+	}
+	result.Remaining = int(math.Max(0, math.Floor(float64(sw.limit)-estimate)))
+	result.Reset = 2*sw.window - elapsed
+	if currCount == 0 {
+		result.Reset = sw.window - elapsed
+	}
+	newState := make([]byte, 24)
+	binary.BigEndian.PutUint64(newState[:8], uint64(windowStart.UnixNano()))
+	binary.BigEndian.PutUint64(newState[8:16], uint64(prevCount))
+	binary.BigEndian.PutUint64(newState[16:], uint64(currCount))
+	return newState, result
+}
+
+type memoryRateLimitEntry struct {
+	state   []byte
+	expires time.Time
+}
+
+// MemoryRateLimitStore: in-memory RateLimitStore, for a single server
+type MemoryRateLimitStore struct {
+	mutex     sync.Mutex
+	entries   map[string]*memoryRateLimitEntry
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		entries:   map[string]*memoryRateLimitEntry{},
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *MemoryRateLimitStore) Update(ctx context.Context, key string, ttl time.Duration, update func(state []byte) []byte) error {
+	now := time.Now()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if now.Sub(s.lastSweep) > time.Minute {
+		for entryKey, entry := range s.entries {
+			if now.After(entry.expires) {
+				delete(s.entries, entryKey)
+			}
+		}
+		s.lastSweep = now
+	}
+	var state []byte
+	entry := s.entries[key]
+	if entry != nil && !now.After(entry.expires) {
+		state = entry.state
+	}
+	s.entries[key] = &memoryRateLimitEntry{
+		state:   update(state),
+		expires: now.Add(ttl),
+	}
+	return nil
+}
+
+// RateLimiter: limits requests of handlers with RateLimit option
+// requests that exceed the limit get TooManyRequests error (HTTP 429) with Retry-After header
+// and all responses get RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers
+// if store or key function fail, the error is logged and request is allowed
+type RateLimiter struct {
+	algorithm RateLimitAlgorithm
+	keyFunc   RateLimitKeyFunc
+	store     RateLimitStore
+	prefix    string
+	now       func() time.Time
+}
+
+// NewRateLimiter: creates a rate limiter with in-memory store
+func NewRateLimiter(algorithm RateLimitAlgorithm, keyFunc RateLimitKeyFunc) *RateLimiter {
+	if algorithm == nil {
+		panic("NewRateLimiter: nil algorithm")
+	}
+	if keyFunc == nil {
+		panic("NewRateLimiter: nil keyFunc")
+	}
+	return &RateLimiter{
+		algorithm: algorithm,
+		keyFunc:   keyFunc,
+		store:     NewMemoryRateLimitStore(),
+		now:       time.Now,
+	}
+}
+
+// Store: set store of states, prefix is added to keys, so limiters can share a store
+func (limiter *RateLimiter) Store(store RateLimitStore, prefix string) *RateLimiter {
+	if store == nil {
+		panic("RateLimiter.Store: nil store")
+	}
+	limiter.store = store
+	limiter.prefix = prefix
+	return limiter
+}
+
+// take: returns nil result if request is not limited
+func (limiter *RateLimiter) take(req Request) (*RateLimitResult, error) {
+	key, err := limiter.keyFunc(req)
+	if err != nil || key == "" {
+		return nil, err
+	}
+	var result *RateLimitResult
+	err = limiter.store.Update(req.Context(), limiter.prefix+key, limiter.algorithm.TTL(), func(state []byte) []byte {
+		newState, stateResult := limiter.algorithm.Take(state, limiter.now())
+		result = stateResult
+		return newState
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RateLimit: limit requests of this handler with limiter, can be given multiple times
+func RateLimit(limiter *RateLimiter) HandlerOption {
+	return func(config *handlerConfig) {
+		config.rateLimiters = append(config.rateLimiters, limiter)
+	}
+}
+
+func durationSeconds(duration time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(duration.Seconds())), 10)
+}
+
+// applyRateLimits: sets rate limit headers, and returns TooManyRequests error if any limiter is exceeded
+// headers are of the limiter with least remaining requests
+func applyRateLimits(w http.ResponseWriter, request ExtendedRequest, config *handlerConfig) error {
+	var minResult *RateLimitResult
+	var minLimiter *RateLimiter
+	var exceeded *RateLimitResult
+	var exceededLimiter *RateLimiter
+	for _, limiter := range config.rateLimiters {
+		result, err := limiter.take(request)
+		if err != nil {
+			request.Logger().Warn("error in rate limiter, request is allowed", slog.String("error", err.Error()))
+			continue
+		}
+		if result == nil {
+			continue
+		}
+		if !result.Allowed && (exceeded == nil || result.RetryAfter > exceeded.RetryAfter) {
+			exceeded = result
+			exceededLimiter = limiter
+		}
+		if minResult == nil || result.Remaining < minResult.Remaining {
+			minResult = result
+			minLimiter = limiter
+		}
+	}
+	if minResult == nil {
+		return nil
+	}
+	wh := w.Header()
+	wh.Set("RateLimit-Limit", strconv.Itoa(minResult.Limit))
+	wh.Set("RateLimit-Remaining", strconv.Itoa(minResult.Remaining))
+	wh.Set("RateLimit-Reset", durationSeconds(minResult.Reset))
+	wh.Set("RateLimit-Policy", minLimiter.algorithm.Policy())
+	if exceeded == nil {
+		return nil
+	}
+	retryAfter := int(math.Ceil(exceeded.RetryAfter.Seconds()))
+	wh.Set("Retry-After", strconv.Itoa(retryAfter))
+	return NewError(
+		TooManyRequests, "rate limit exceeded", nil,
+	).AddPublic(
+		"limit", exceeded.Limit,
+	).AddPublic(
+		"policy", exceededLimiter.algorithm.Policy(),
+	).AddPublic(
+		"retryAfter", retryAfter,
+	)
+}
@@ -0,0 +1,199 @@
+package ripo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ilius/is/v2"
+)
+
+func TestTokenBucket(t *testing.T) {
+	is := is.New(t)
+	tb := TokenBucket(2, 10*time.Second)
+	is.Equal("2;w=10", tb.Policy())
+	now := time.Unix(1000, 0)
+	state, result := tb.Take(nil, now)
+	is.True(result.Allowed)
+	is.Equal(1, result.Remaining)
+	is.Equal(5*time.Second, result.Reset)
+	state, result = tb.Take(state, now)
+	is.True(result.Allowed)
+	is.Equal(0, result.Remaining)
+	state, result = tb.Take(state, now.Add(time.Second))
+	is.False(result.Allowed)
+	is.Equal(4*time.Second, result.RetryAfter)
+	state, result = tb.Take(state, now.Add(5*time.Second))
+	is.True(result.Allowed)
+	is.Equal(0, result.Remaining)
+	// refilled, but not more than limit
+	_, result = tb.Take(state, now.Add(time.Hour))
+	is.True(result.Allowed)
+	is.Equal(1, result.Remaining)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	is := is.New(t)
+	sw := SlidingWindow(2, 10*time.Second)
+	is.Equal("2;w=10", sw.Policy())
+	now := time.Unix(1000, 0)
+	state, result := sw.Take(nil, now)
+	is.True(result.Allowed)
+	is.Equal(1, result.Remaining)
+	state, result = sw.Take(state, now.Add(time.Second))
+	is.True(result.Allowed)
+	is.Equal(0, result.Remaining)
+	state, result = sw.Take(state, now.Add(2*time.Second))
+	is.False(result.Allowed)
+	// next window at 10s, then half of current window must be out of sliding window
+	is.Equal(13*time.Second, result.RetryAfter)
+	_, result = sw.Take(state, now.Add(12*time.Second))
+	is.False(result.Allowed)
+	is.Equal(3*time.Second, result.RetryAfter)
+	state, result = sw.Take(state, now.Add(15*time.Second))
+	is.True(result.Allowed)
+	is.Equal(0, result.Remaining)
+	// previous window is too old
+	_, result = sw.Take(state, now.Add(30*time.Second))
+	is.True(result.Allowed)
+	is.Equal(1, result.Remaining)
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	is := is.New(t)
+	store := NewMemoryRateLimitStore()
+	ctx := context.Background()
+	var got []byte
+	is.NotErr(store.Update(ctx, "a", time.Minute, func(state []byte) []byte {
+		got = state
+		return []byte("1")
+	}))
+	is.True(got == nil)
+	is.NotErr(store.Update(ctx, "a", -time.Second, func(state []byte) []byte {
+		got = state
+		return []byte("2")
+	}))
+	is.Equal("1", string(got))
+	// expired
+	is.NotErr(store.Update(ctx, "a", time.Minute, func(state []byte) []byte {
+		got = state
+		return []byte("3")
+	}))
+	is.True(got == nil)
+}
+
+type failingRateLimitStore struct{}
+
+func (s failingRateLimitStore) Update(ctx context.Context, key string, ttl time.Duration, update func(state []byte) []byte) error {
+	return errors.New("store is down")
+}
+
+func TestRateLimit(t *testing.T) {
+	is := is.New(t)
+	limiter := NewRateLimiter(TokenBucket(2, time.Minute), RateLimitByRemoteIP)
+	now := time.Unix(1000, 0)
+	limiter.now = func() time.Time { return now }
+	handlerFunc := TranslateHandler(func(req Request) (*Response, error) {
+		return NoContent(), nil
+	}, RateLimit(limiter))
+	doRequest := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handlerFunc(w, r)
+		return w
+	}
+	{
+		w := doRequest("10.0.0.1:1234")
+		is.Equal(http.StatusNoContent, w.Code)
+		is.Equal("2", w.Header().Get("RateLimit-Limit"))
+		is.Equal("1", w.Header().Get("RateLimit-Remaining"))
+		is.Equal("30", w.Header().Get("RateLimit-Reset"))
+		is.Equal("2;w=60", w.Header().Get("RateLimit-Policy"))
+		is.Equal("", w.Header().Get("Retry-After"))
+	}
+	is.Equal(http.StatusNoContent, doRequest("10.0.0.1:1235").Code)
+	{
+		w := doRequest("10.0.0.1:1236")
+		is.Equal(http.StatusTooManyRequests, w.Code)
+		is.Equal("0", w.Header().Get("RateLimit-Remaining"))
+		is.Equal("30", w.Header().Get("Retry-After"))
+		body := map[string]any{}
+		is.NotErr(json.Unmarshal(w.Body.Bytes(), &body))
+		is.Equal("TooManyRequests", body["code"])
+		is.Equal("rate limit exceeded", body["error"])
+		is.Equal(map[string]any{
+			"limit":      float64(2),
+			"policy":     "2;w=60",
+			"retryAfter": float64(30),
+		}, body["details"])
+	}
+	// other client
+	is.Equal(http.StatusNoContent, doRequest("10.0.0.2:1234").Code)
+	now = now.Add(30 * time.Second)
+	is.Equal(http.StatusNoContent, doRequest("10.0.0.1:1234").Code)
+}
+
+type rateLimitTestUserKey struct{}
+
+func TestRateLimit_KeyFuncs(t *testing.T) {
+	is := is.New(t)
+	byHeader := NewRateLimiter(SlidingWindow(1, time.Minute), RateLimitByHeader("X-Api-Key"))
+	byUser := NewRateLimiter(SlidingWindow(1, time.Minute), RateLimitByUser(rateLimitTestUserKey{}))
+	handlerFunc := TranslateHandler(func(req Request) (*Response, error) {
+		return NoContent(), nil
+	}, RateLimit(byHeader), RateLimit(byUser))
+	doRequest := func(apiKey string, user string) int {
+		r := httptest.NewRequest("GET", "/", nil)
+		if apiKey != "" {
+			r.Header.Set("X-Api-Key", apiKey)
+		}
+		if user != "" {
+			r = r.WithContext(context.WithValue(r.Context(), rateLimitTestUserKey{}, user))
+		}
+		w := httptest.NewRecorder()
+		handlerFunc(w, r)
+		return w.Code
+	}
+	is.Equal(http.StatusNoContent, doRequest("", ""))
+	is.Equal(http.StatusNoContent, doRequest("", ""))
+	is.Equal(http.StatusNoContent, doRequest("k1", ""))
+	is.Equal(http.StatusTooManyRequests, doRequest("k1", ""))
+	is.Equal(http.StatusNoContent, doRequest("k2", "u1"))
+	is.Equal(http.StatusTooManyRequests, doRequest("k3", "u1"))
+	is.Equal(http.StatusNoContent, doRequest("", "u2"))
+}
+
+func TestRateLimit_StoreError(t *testing.T) {
+	is := is.New(t)
+	limiter := NewRateLimiter(TokenBucket(1, time.Minute), RateLimitByRemoteIP).Store(failingRateLimitStore{}, "api:")
+	handlerFunc := TranslateHandler(func(req Request) (*Response, error) {
+		return NoContent(), nil
+	}, RateLimit(limiter))
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		handlerFunc(w, httptest.NewRequest("GET", "/", nil))
+		is.Equal(http.StatusNoContent, w.Code)
+		is.Equal("", w.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestRateLimit_Invalid(t *testing.T) {
+	is := is.New(t)
+	is.ShouldPanic(func() {
+		TokenBucket(0, time.Second)
+	})
+	is.ShouldPanic(func() {
+		SlidingWindow(1, 0)
+	})
+	is.ShouldPanic(func() {
+		NewRateLimiter(nil, RateLimitByRemoteIP)
+	})
+	is.ShouldPanic(func() {
+		NewRateLimiter(TokenBucket(1, time.Second), nil)
+	})
+}
@@ -2,13 +2,16 @@ package ripo
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
 	"reflect"
+	"sync"
 	"time"
 )
 
@@ -38,6 +41,14 @@ type Request interface {
 	GetTime(key string, sources ...FromX) (*time.Time, error)
 	GetObject(key string, _type reflect.Type, sources ...FromX) (any, error)
 
+	Pagination() (*Pagination, error)
+
+	RequestID() string
+
+	Logger() *slog.Logger // with handler name and request metadata as attributes
+
+	Lock(ctx context.Context, key string, ttl time.Duration) error
+
 	FullMap() map[string]any
 }
 
@@ -69,6 +80,12 @@ func SetDefaultParamSources(sources ...FromX) {
 type requestImp struct {
 	r           *http.Request // must be set initially
 	handlerName string        // must be set initially
+	maxBodySize int64         // 0 means no limit
+	requestID   string
+	logger      *slog.Logger // nil means global logger
+	lockMutex   sync.Mutex
+	lockOwner   string
+	lockKeys    []string
 	body        []byte
 	bodyErr     error
 	bodyMap     map[string]any
@@ -108,17 +125,84 @@ func (req *requestImp) Body() ([]byte, error) {
 	if req.r.Body == nil {
 		return nil, nil
 	}
-	body, err := ioutil.ReadAll(req.r.Body)
+	limit := req.maxBodySize
+	if limit > 0 && req.r.ContentLength > limit {
+		req.bodyErr = bodyTooLargeError(limit, req.r.ContentLength)
+		return nil, req.bodyErr
+	}
+	contentEncoding := req.r.Header.Get("Content-Encoding")
+	reader, encodings, err := decompressBody(req.r.Body, contentEncoding)
 	if err != nil {
 		req.bodyErr = err
 		return nil, err
 	}
+	if limit == 0 && len(encodings) > 0 {
+		limit = maxDecompressedBodySize
+	}
+	if limit > 0 {
+		// read one extra byte to know if body exceeds the limit
+		// for compressed body, limit is applied to decompressed size
+		reader = io.LimitReader(reader, limit+1)
+	}
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		if len(encodings) > 0 {
+			err = invalidCompressedBodyError(encodings[len(encodings)-1], err)
+		}
+		req.bodyErr = err
+		return nil, err
+	}
+	if limit > 0 && int64(len(body)) > limit {
+		rpcErr := bodyTooLargeError(limit, int64(len(body)))
+		if len(encodings) > 0 {
+			rpcErr.Add("contentEncoding", contentEncoding)
+		}
+		req.bodyErr = rpcErr
+		return nil, req.bodyErr
+	}
 	req.body = body
 	req.r.Body.Close()
 	req.r.Body = nil
 	return body, nil
 }
 
+// parseForm: calls r.ParseForm with body size limit applied to urlencoded form body
+// returns PayloadTooLarge error if form body exceeds the limit
+func (req *requestImp) parseForm(w http.ResponseWriter) error {
+	r := req.r
+	limit := req.maxBodySize
+	if limit == 0 && r.Header.Get("Content-Encoding") != "" {
+		limit = maxDecompressedBodySize
+	}
+	if limit <= 0 || r.Body == nil {
+		return r.ParseForm()
+	}
+	body := r.Body
+	r.Body = http.MaxBytesReader(w, body, limit)
+	err := r.ParseForm()
+	// body is untouched if it's not a form body
+	r.Body = body
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		bodySize := r.ContentLength
+		if bodySize <= limit {
+			bodySize = limit + 1
+		}
+		return bodyTooLargeError(limit, bodySize)
+	}
+	return err
+}
+
+// bodySize is the observed size, which is Content-Length header if given,
+// or otherwise the number of bytes read, stopped right after exceeding the limit
+func bodyTooLargeError(limit int64, bodySize int64) RPCError {
+	return NewError(
+		PayloadTooLarge,
+		fmt.Sprintf("request body is too large, must be at most %d bytes", limit),
+		nil,
+	).Add("maxBodySize", limit).Add("bodySize", bodySize)
+}
+
 func (req *requestImp) BodyMap() (map[string]any, error) {
 	if req.bodyMap != nil {
 		return req.bodyMap, nil
@@ -133,9 +217,14 @@ func (req *requestImp) BodyMap() (map[string]any, error) {
 		return nil, err
 	}
 	if len(body) > 0 {
-		err = json.Unmarshal(body, &data)
+		decoder, err := findDecoder(req.r.Header.Get("Content-Type"))
 		if err != nil {
-			err = NewError(InvalidArgument, "request body is not a valid json", err)
+			req.bodyMapErr = err
+			return nil, err
+		}
+		err = decoder.decode(body, &data)
+		if err != nil {
+			err = NewError(InvalidArgument, fmt.Sprintf("request body is not a valid %v", decoder.name), err)
 			req.bodyMapErr = err
 			return nil, err
 		}
@@ -149,9 +238,13 @@ func (req *requestImp) BodyTo(model any) error {
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal(body, model)
+	decoder, err := findDecoder(req.r.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+	err = decoder.decode(body, model)
 	if err != nil {
-		return NewError(InvalidArgument, "request body is not a valid json", err)
+		return NewError(InvalidArgument, fmt.Sprintf("request body is not a valid %v", decoder.name), err)
 	}
 	return nil
 }
@@ -0,0 +1,83 @@
+package ripo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIDHeader: request and response header of request ID
+const RequestIDHeader = "X-Request-Id"
+
+const maxRequestIDLength = 128
+
+var requestIDGenerator = generateRequestID
+
+// SetRequestIDGenerator: set the function that generates request ID, if request does not have
+// a valid X-Request-Id header, nil disables generating request ID
+func SetRequestIDGenerator(generator func() string) {
+	requestIDGenerator = generator
+}
+
+// generateRequestID: 32 random hex digits
+func generateRequestID() string {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID: returns a context with request ID, that is used by handlers
+// and client package for outbound calls
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext: returns request ID of context, or empty string
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// isValidRequestID: client given request ID must be printable ASCII with no spaces, and not too long
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < 0x21 || requestID[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// setupRequestID: finds request ID in context or header, or generates it
+// and sets it in response header and context of returned request
+func setupRequestID(w http.ResponseWriter, r *http.Request) (*http.Request, string) {
+	requestID := RequestIDFromContext(r.Context())
+	if requestID == "" {
+		requestID = r.Header.Get(RequestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = ""
+		}
+		if requestID == "" && requestIDGenerator != nil {
+			requestID = requestIDGenerator()
+		}
+		if requestID == "" {
+			return r, ""
+		}
+		r = r.WithContext(ContextWithRequestID(r.Context(), requestID))
+	}
+	w.Header().Set(RequestIDHeader, requestID)
+	return r, requestID
+}
+
+func (req *requestImp) RequestID() string {
+	return req.requestID
+}
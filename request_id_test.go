@@ -0,0 +1,108 @@
+package ripo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+func TestRequestID(t *testing.T) {
+	is := is.New(t)
+	SetRequestIDGenerator(func() string { return "generated" })
+	defer SetRequestIDGenerator(nil)
+	var dispatchedErr RPCError
+	SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {
+		dispatchedErr = rpcErr
+	})
+	defer SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {})
+	handlerFunc := TranslateHandler(func(req Request) (*Response, error) {
+		if req.Header("X-Fail") != "" {
+			return nil, NewError(NotFound, "not found", nil)
+		}
+		return &Response{Data: req.RequestID() + " " + RequestIDFromContext(req.Context())}, nil
+	})
+	doRequest := func(header http.Header) *httptest.ResponseRecorder {
+		r, err := http.NewRequest("GET", "/", nil)
+		is.NotErr(err)
+		for key, values := range header {
+			r.Header[key] = values
+		}
+		w := httptest.NewRecorder()
+		handlerFunc(w, r)
+		return w
+	}
+	{
+		w := doRequest(nil)
+		is.Equal("generated generated", w.Body.String())
+		is.Equal("generated", w.Header().Get(RequestIDHeader))
+	}
+	{
+		w := doRequest(http.Header{RequestIDHeader: {"abc-123"}})
+		is.Equal("abc-123 abc-123", w.Body.String())
+		is.Equal("abc-123", w.Header().Get(RequestIDHeader))
+	}
+	{
+		w := doRequest(http.Header{RequestIDHeader: {"has space"}})
+		is.Equal("generated generated", w.Body.String())
+		is.Equal("generated", w.Header().Get(RequestIDHeader))
+	}
+	{
+		w := doRequest(http.Header{
+			RequestIDHeader: {"abc-123"},
+			"X-Fail":        {"1"},
+		})
+		is.Equal(http.StatusNotFound, w.Code)
+		is.Equal(`{"code":"NotFound","error":"not found","requestId":"abc-123"}`, w.Body.String())
+		is.Equal("abc-123", w.Header().Get(RequestIDHeader))
+		is.Equal("abc-123", dispatchedErr.Details()["requestId"])
+	}
+	SetRequestIDGenerator(nil)
+	{
+		w := doRequest(nil)
+		is.Equal(" ", w.Body.String())
+		is.Equal("", w.Header().Get(RequestIDHeader))
+	}
+}
+
+func TestRequestIDFromContext(t *testing.T) {
+	is := is.New(t)
+	is.Equal("", RequestIDFromContext(context.Background()))
+	ctx := ContextWithRequestID(context.Background(), "abc")
+	is.Equal("abc", RequestIDFromContext(ctx))
+	{
+		r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+		r.Header.Set(RequestIDHeader, "other")
+		w := httptest.NewRecorder()
+		r, requestID := setupRequestID(w, r)
+		is.Equal("abc", requestID)
+		is.Equal("abc", RequestIDFromContext(r.Context()))
+		is.Equal("abc", w.Header().Get(RequestIDHeader))
+	}
+}
+
+func TestGenerateRequestID(t *testing.T) {
+	is := is.New(t)
+	id1 := generateRequestID()
+	id2 := generateRequestID()
+	is.Equal(32, len(id1))
+	is.True(id1 != id2)
+	is.True(isValidRequestID(id1))
+}
+
+func TestIsValidRequestID(t *testing.T) {
+	is := is.New(t)
+	is.True(isValidRequestID("abc-123_ABC.xyz"))
+	is.False(isValidRequestID(""))
+	is.False(isValidRequestID("a b"))
+	is.False(isValidRequestID("a\nb"))
+	is.False(isValidRequestID("é"))
+	long := make([]byte, maxRequestIDLength+1)
+	for i := range long {
+		long[i] = 'a'
+	}
+	is.False(isValidRequestID(string(long)))
+	is.True(isValidRequestID(string(long[:maxRequestIDLength])))
+}
@@ -1,10 +1,13 @@
 package ripo
 
 import (
+	"context"
 	"io"
+	"log/slog"
 	"net/url"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 )
@@ -109,6 +112,22 @@ func Test_RequestMock(t *testing.T) {
 		mockReq.EXPECT().GetObject("person", PersonType, FromBody).Return(nil, nil)
 		mockReq.GetObject("person", PersonType, FromBody)
 	}
+	{
+		mockReq.EXPECT().Pagination().Return(&Pagination{Limit: 20}, nil)
+		mockReq.Pagination()
+	}
+	{
+		mockReq.EXPECT().RequestID().Return("abc")
+		mockReq.RequestID()
+	}
+	{
+		mockReq.EXPECT().Logger().Return(slog.Default())
+		mockReq.Logger()
+	}
+	{
+		mockReq.EXPECT().Lock(gomock.Any(), "order:123", time.Second).Return(nil)
+		mockReq.Lock(context.Background(), "order:123", time.Second)
+	}
 }
 
 func Test_ExtendedRequestMock(t *testing.T) {
@@ -211,4 +230,20 @@ func Test_ExtendedRequestMock(t *testing.T) {
 		mockReq.EXPECT().GetObject("person", PersonType, FromBody).Return(nil, nil)
 		mockReq.GetObject("person", PersonType, FromBody)
 	}
+	{
+		mockReq.EXPECT().Pagination().Return(&Pagination{Limit: 20}, nil)
+		mockReq.Pagination()
+	}
+	{
+		mockReq.EXPECT().RequestID().Return("abc")
+		mockReq.RequestID()
+	}
+	{
+		mockReq.EXPECT().Logger().Return(slog.Default())
+		mockReq.Logger()
+	}
+	{
+		mockReq.EXPECT().Lock(gomock.Any(), "order:123", time.Second).Return(nil)
+		mockReq.Lock(context.Background(), "order:123", time.Second)
+	}
 }
@@ -4,10 +4,79 @@ import "net/http"
 
 type Response struct {
 	// Data: map or struct with json tags
+	// or []byte or string to be written as is
+	// or io.Reader to be streamed as is (chunked)
+	// or StreamFunc or a channel to be streamed as NDJSON
+	// or *File to be served with support for Range and conditional requests
 	Data any
 
-	Header http.Header
+	// ContentType: media type to encode Data with, overriding the negotiation
+	// based on Accept header, must be registered with RegisterEncoder
+	// not used if Data is []byte or string
+	// if Data is io.Reader, it's used as Content-Type header
+	ContentType string
+
+	// StatusCode: HTTP status code of a successful response, defaults to 200
+	StatusCode int
+
+	Header  http.Header
+	Cookies []*http.Cookie
+
+	// NoBody: only write status code and headers, ignore Data
+	// automatically true for 204 and 304 status codes
+	NoBody bool
+
+	// DisableCompression: do not compress body, even if client accepts it
+	DisableCompression bool
 
 	RedirectPath       string
 	RedirectStatusCode int
 }
+
+// Created: 201 response, with optional Location header
+func Created(data any, location string) *Response {
+	res := &Response{
+		Data:       data,
+		StatusCode: http.StatusCreated,
+	}
+	if location != "" {
+		res.Header = http.Header{
+			"Location": []string{location},
+		}
+	}
+	return res
+}
+
+// Accepted: 202 response, typically for async jobs that are queued but not finished
+func Accepted(data any) *Response {
+	return &Response{
+		Data:       data,
+		StatusCode: http.StatusAccepted,
+	}
+}
+
+// NoContent: 204 response with no body
+func NoContent() *Response {
+	return &Response{
+		StatusCode: http.StatusNoContent,
+		NoBody:     true,
+	}
+}
+
+func (res *Response) statusCode() int {
+	if res.StatusCode == 0 {
+		return http.StatusOK
+	}
+	return res.StatusCode
+}
+
+func (res *Response) hasBody() bool {
+	if res.NoBody {
+		return false
+	}
+	switch res.statusCode() {
+	case http.StatusNoContent, http.StatusNotModified:
+		return false
+	}
+	return true
+}
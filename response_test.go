@@ -0,0 +1,108 @@
+package ripo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+func TestResponse_Created(t *testing.T) {
+	is := is.New(t)
+	handlerFunc := TranslateHandler(func(req Request) (res *Response, err error) {
+		return Created(map[string]string{"id": "1234"}, "/items/1234"), nil
+	})
+	r, err := http.NewRequest("POST", "", strings.NewReader(`{}`))
+	if err != nil {
+		panic(err)
+	}
+	w := httptest.NewRecorder()
+	handlerFunc(w, r)
+	is.Equal(http.StatusCreated, w.Code)
+	is.Equal("/items/1234", w.Header().Get("Location"))
+	is.Equal("application/json; charset=UTF-8", w.Header().Get("Content-Type"))
+	body := strings.TrimSpace(w.Body.String())
+	is.Equal("{\"id\":\"1234\"}", body)
+}
+
+func TestResponse_Accepted(t *testing.T) {
+	is := is.New(t)
+	handlerFunc := TranslateHandler(func(req Request) (res *Response, err error) {
+		return Accepted(map[string]string{"jobId": "5"}), nil
+	})
+	r, err := http.NewRequest("POST", "", strings.NewReader(`{}`))
+	if err != nil {
+		panic(err)
+	}
+	w := httptest.NewRecorder()
+	handlerFunc(w, r)
+	is.Equal(http.StatusAccepted, w.Code)
+	is.Equal("", w.Header().Get("Location"))
+	body := strings.TrimSpace(w.Body.String())
+	is.Equal("{\"jobId\":\"5\"}", body)
+}
+
+func TestResponse_NoContent(t *testing.T) {
+	is := is.New(t)
+	handlerFunc := TranslateHandler(func(req Request) (res *Response, err error) {
+		return NoContent(), nil
+	})
+	r, err := http.NewRequest("DELETE", "", nil)
+	if err != nil {
+		panic(err)
+	}
+	w := httptest.NewRecorder()
+	handlerFunc(w, r)
+	is.Equal(http.StatusNoContent, w.Code)
+	is.Equal("", w.Header().Get("Content-Type"))
+	is.Equal("", w.Body.String())
+}
+
+func TestResponse_NoBody(t *testing.T) {
+	is := is.New(t)
+	handlerFunc := TranslateHandler(func(req Request) (res *Response, err error) {
+		return &Response{
+			Data:   map[string]string{"ignored": "yes"},
+			NoBody: true,
+		}, nil
+	})
+	r, err := http.NewRequest("GET", "", nil)
+	if err != nil {
+		panic(err)
+	}
+	w := httptest.NewRecorder()
+	handlerFunc(w, r)
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal("", w.Body.String())
+}
+
+func TestResponse_StatusCodeAndCookies(t *testing.T) {
+	is := is.New(t)
+	handlerFunc := TranslateHandler(func(req Request) (res *Response, err error) {
+		return &Response{
+			Data:       map[string]string{"status": "partial"},
+			StatusCode: http.StatusPartialContent,
+			Cookies: []*http.Cookie{
+				{Name: "session", Value: "abc", HttpOnly: true},
+				{Name: "theme", Value: "dark"},
+			},
+		}, nil
+	})
+	r, err := http.NewRequest("GET", "", nil)
+	if err != nil {
+		panic(err)
+	}
+	w := httptest.NewRecorder()
+	handlerFunc(w, r)
+	is.Equal(http.StatusPartialContent, w.Code)
+	cookies := w.Result().Cookies()
+	is.Equal(2, len(cookies))
+	is.Equal("session", cookies[0].Name)
+	is.Equal("abc", cookies[0].Value)
+	is.True(cookies[0].HttpOnly)
+	is.Equal("theme", cookies[1].Name)
+	body := strings.TrimSpace(w.Body.String())
+	is.Equal("{\"status\":\"partial\"}", body)
+}
@@ -0,0 +1,264 @@
+package ripo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// places of parameters, used in Param.In
+const (
+	ParamInPath   = "path"
+	ParamInQuery  = "query"
+	ParamInHeader = "header"
+	ParamInBody   = "body"
+)
+
+// Param: metadata of a route parameter, used for documentation
+type Param struct {
+	Name        string
+	In          string       // ParamInPath, ParamInQuery, ParamInHeader or ParamInBody
+	Type        reflect.Type // nil means string
+	Required    bool
+	Description string
+}
+
+// Route: a handler registered in Router, with its metadata
+// metadata methods return the route, so they can be chained
+type Route struct {
+	method   string
+	pattern  string
+	segments []string
+	handler  Handler
+	httpFunc http.HandlerFunc
+
+	operationID string
+	summary     string
+	description string
+	tags        []string
+	params      []*Param
+	bodyType    reflect.Type
+	resultType  reflect.Type
+	errorCodes  []Code
+}
+
+func (route *Route) Method() string {
+	return route.method
+}
+
+func (route *Route) Pattern() string {
+	return route.pattern
+}
+
+// OperationID: set operationId, default is the handler function name
+func (route *Route) OperationID(id string) *Route {
+	route.operationID = id
+	return route
+}
+
+func (route *Route) Summary(summary string) *Route {
+	route.summary = summary
+	return route
+}
+
+func (route *Route) Description(description string) *Route {
+	route.description = description
+	return route
+}
+
+func (route *Route) Tags(tags ...string) *Route {
+	route.tags = append(route.tags, tags...)
+	return route
+}
+
+// Params: add parameters, replacing previous parameter with the same name and place
+func (route *Route) Params(params ...*Param) *Route {
+	for _, param := range params {
+		replaced := false
+		for index, oldParam := range route.params {
+			if oldParam.Name == param.Name && oldParam.In == param.In {
+				route.params[index] = param
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			route.params = append(route.params, param)
+		}
+	}
+	return route
+}
+
+// Body: set type of request body to type of model
+func (route *Route) Body(model any) *Route {
+	route.bodyType = reflect.TypeOf(model)
+	return route
+}
+
+// Returns: set type of response data to type of model
+func (route *Route) Returns(model any) *Route {
+	route.resultType = reflect.TypeOf(model)
+	return route
+}
+
+// Errors: add error codes that handler may return
+func (route *Route) Errors(codes ...Code) *Route {
+	route.errorCodes = append(route.errorCodes, codes...)
+	return route
+}
+
+// match: returns path parameters and number of literal segments, or nil if path does not match
+func (route *Route) match(segments []string) (map[string]string, int) {
+	if len(segments) != len(route.segments) {
+		return nil, 0
+	}
+	params := map[string]string{}
+	literals := 0
+	for index, patternSegment := range route.segments {
+		segment := segments[index]
+		name, isParam := patternParamName(patternSegment)
+		if !isParam {
+			if segment != patternSegment {
+				return nil, 0
+			}
+			literals++
+			continue
+		}
+		value, err := url.PathUnescape(segment)
+		if err != nil || value == "" {
+			return nil, 0
+		}
+		params[name] = value
+	}
+	return params, literals
+}
+
+func patternParamName(segment string) (string, bool) {
+	if len(segment) > 2 && segment[0] == '{' && segment[len(segment)-1] == '}' {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+type routePatternKey struct{}
+
+// routePatternFromContext: returns pattern of matched route of Router, or empty string
+func routePatternFromContext(ctx context.Context) string {
+	pattern, _ := ctx.Value(routePatternKey{}).(string)
+	return pattern
+}
+
+// Router: routes requests to handlers by method and path pattern like "/users/{id}"
+// path parameters are accessible with FromPath source
+// routes with more literal segments take precedence, like "/users/me" over "/users/{id}"
+type Router struct {
+	routes  []*Route
+	options []HandlerOption
+}
+
+// NewRouter: options are applied to all handlers, before options of each handler
+func NewRouter(options ...HandlerOption) *Router {
+	return &Router{
+		options: options,
+	}
+}
+
+// Handle: register handler for method and pattern
+// path parameters in pattern are documented as required string parameters, unless set by route.Params
+func (router *Router) Handle(method string, pattern string, handler Handler, options ...HandlerOption) *Route {
+	if !strings.HasPrefix(pattern, "/") {
+		panic(fmt.Sprintf("Router.Handle: pattern %#v must start with /", pattern))
+	}
+	method = strings.ToUpper(method)
+	segments := strings.Split(pattern, "/")
+	for _, other := range router.routes {
+		if other.method == method && other.pattern == pattern {
+			panic(fmt.Sprintf("Router.Handle: duplicate route %v %v", method, pattern))
+		}
+	}
+	allOptions := append(append([]HandlerOption{}, router.options...), options...)
+	route := &Route{
+		method:   method,
+		pattern:  pattern,
+		segments: segments,
+		handler:  handler,
+		httpFunc: TranslateHandler(handler, allOptions...),
+	}
+	for _, segment := range segments {
+		name, isParam := patternParamName(segment)
+		if isParam {
+			route.params = append(route.params, &Param{
+				Name:     name,
+				In:       ParamInPath,
+				Required: true,
+			})
+		}
+	}
+	info := TypedInfoOf(handler)
+	if info != nil {
+		route.documentTyped(info)
+	}
+	router.routes = append(router.routes, route)
+	return route
+}
+
+// Routes: returns registered routes, in the order they are registered
+func (router *Router) Routes() []*Route {
+	return append([]*Route{}, router.routes...)
+}
+
+func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(r.URL.EscapedPath(), "/")
+	var bestRoute *Route
+	var bestParams map[string]string
+	bestLiterals := -1
+	allowed := map[string]bool{}
+	for _, route := range router.routes {
+		params, literals := route.match(segments)
+		if params == nil {
+			continue
+		}
+		allowed[route.method] = true
+		if route.method != r.Method && !(r.Method == http.MethodHead && route.method == http.MethodGet) {
+			continue
+		}
+		// exact method takes precedence over GET for HEAD requests
+		if literals > bestLiterals || literals == bestLiterals && route.method == r.Method {
+			bestRoute = route
+			bestParams = params
+			bestLiterals = literals
+		}
+	}
+	if bestRoute != nil {
+		ctx := withPathParams(r.Context(), bestParams)
+		ctx = context.WithValue(ctx, routePatternKey{}, bestRoute.pattern)
+		bestRoute.httpFunc(w, r.WithContext(ctx))
+		return
+	}
+	// matched routes write their own access log and metrics
+	config := newHandlerConfig(router.options)
+	w, request, finish := startRequest(w, r, config, "Router")
+	defer finish()
+	if len(allowed) > 0 {
+		methods := make([]string, 0, len(allowed))
+		for method := range allowed {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		writeError(
+			w, request,
+			NewError(Unimplemented, "method not allowed", nil).Add("method", r.Method).Add("path", r.URL.Path),
+			http.StatusMethodNotAllowed,
+		)
+		return
+	}
+	handleError(
+		NewError(NotFound, "not found", nil).Add("method", r.Method).Add("path", r.URL.Path),
+		w, request,
+	)
+}
@@ -0,0 +1,102 @@
+package ripo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+func newRouterTestRouter() *Router {
+	router := NewRouter()
+	router.Handle("GET", "/users/{id}", func(req Request) (*Response, error) {
+		id, err := req.GetInt("id", FromPath)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{Data: map[string]int{"id": *id}}, nil
+	})
+	router.Handle("GET", "/users/me", func(req Request) (*Response, error) {
+		return &Response{Data: "me"}, nil
+	})
+	router.Handle("DELETE", "/users/{id}", func(req Request) (*Response, error) {
+		return NoContent(), nil
+	})
+	router.Handle("GET", "/files/{name}/meta", func(req Request) (*Response, error) {
+		name, err := req.GetString("name", FromPath)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{Data: *name + " " + PathParams(req.Context())["name"]}, nil
+	})
+	return router
+}
+
+func TestRouter(t *testing.T) {
+	is := is.New(t)
+	router := newRouterTestRouter()
+	{
+		w := doTestRequest(router, "GET", "/users/12", nil, "")
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal(`{"id":12}`, w.Body.String())
+	}
+	{
+		w := doTestRequest(router, "GET", "/users/me", nil, "")
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("me", w.Body.String())
+	}
+	{
+		w := doTestRequest(router, "GET", "/users/abc", nil, "")
+		is.Equal(http.StatusBadRequest, w.Code)
+		is.Equal(`{"code":"InvalidArgument","error":"invalid 'id', must be integer"}`, w.Body.String())
+	}
+	{
+		w := doTestRequest(router, "DELETE", "/users/12", nil, "")
+		is.Equal(http.StatusNoContent, w.Code)
+	}
+	{
+		w := doTestRequest(router, "HEAD", "/users/12", nil, "")
+		is.Equal(http.StatusOK, w.Code)
+	}
+	{
+		w := doTestRequest(router, "GET", "/files/a%2Fb.txt/meta", nil, "")
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("a/b.txt a/b.txt", w.Body.String())
+	}
+}
+
+func TestRouter_NotFound(t *testing.T) {
+	is := is.New(t)
+	router := newRouterTestRouter()
+	for _, path := range []string{"/users", "/users/", "/users/12/x", "/files//meta"} {
+		w := doTestRequest(router, "GET", path, nil, "")
+		is.Msg("path=%v", path).Equal(http.StatusNotFound, w.Code)
+		is.Msg("path=%v", path).Equal(`{"code":"NotFound","error":"not found"}`, w.Body.String())
+	}
+	w := doTestRequest(router, "PUT", "/users/12", nil, "")
+	is.Equal(http.StatusMethodNotAllowed, w.Code)
+	is.Equal("DELETE, GET", w.Header().Get("Allow"))
+	is.Equal(`{"code":"Unimplemented","error":"method not allowed"}`, w.Body.String())
+	{
+		r, err := http.NewRequest("PUT", "/users/12", nil)
+		is.NotErr(err)
+		r.Header.Set(RequestIDHeader, "abc-123")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		is.Equal(http.StatusMethodNotAllowed, w.Code)
+		is.Equal("abc-123", w.Header().Get(RequestIDHeader))
+		is.Equal(`{"code":"Unimplemented","error":"method not allowed","requestId":"abc-123"}`, w.Body.String())
+	}
+}
+
+func TestRouter_Panics(t *testing.T) {
+	is := is.New(t)
+	router := newRouterTestRouter()
+	is.ShouldPanic(func() {
+		router.Handle("GET", "users", func(req Request) (*Response, error) { return nil, nil })
+	})
+	is.ShouldPanic(func() {
+		router.Handle("get", "/users/me", func(req Request) (*Response, error) { return nil, nil })
+	})
+}
@@ -0,0 +1,258 @@
+package ripo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event: a Server-Sent Event
+type Event struct {
+	ID    string        // optional, client sends back the last one in Last-Event-ID when reconnecting
+	Event string        // optional event type, client treats empty as "message"
+	Data  any           // string and []byte are sent as is, other values are json-encoded
+	Retry time.Duration // optional reconnection time hint for client
+}
+
+type EventStream interface {
+	Send(event *Event) error
+	SendData(data any) error
+	Comment(text string) error
+
+	// LastEventID: value of Last-Event-ID header, sent by client when resuming
+	LastEventID() string
+
+	// Context: done when the client is gone (or the handler is returned)
+	Context() context.Context
+}
+
+// SSEHandler: handler for Server-Sent Events, the stream is closed when it returns
+// Returning an error before sending any event, is the same as returning error from a normal Handler
+// Returning an error after that, sends an event with type "error" and terminates the stream
+type SSEHandler func(req Request, stream EventStream) error
+
+// sseHeartbeatInterval: interval of sending heartbeat comments to keep the connection alive
+var sseHeartbeatInterval = 15 * time.Second
+
+// SetSSEHeartbeatInterval: set global interval of heartbeat comments in SSE streams
+// can be overridden per handler with SSEHeartbeatInterval option, 0 disables heartbeats
+// heartbeats are sent only after the stream is started by handler (with an event or comment)
+func SetSSEHeartbeatInterval(interval time.Duration) {
+	if interval < 0 {
+		panic("SetSSEHeartbeatInterval: negative interval")
+	}
+	sseHeartbeatInterval = interval
+}
+
+type eventStreamImp struct {
+	w           http.ResponseWriter
+	ctx         context.Context
+	lastEventID string
+
+	mu      sync.Mutex
+	started bool
+	closed  bool
+}
+
+func (s *eventStreamImp) LastEventID() string {
+	return s.lastEventID
+}
+
+func (s *eventStreamImp) Context() context.Context {
+	return s.ctx
+}
+
+// write: writes the given bytes, starting the stream if it's not started
+// must be called with s.mu locked
+func (s *eventStreamImp) write(data []byte) error {
+	if s.closed {
+		return NewError(Canceled, "", fmt.Errorf("event stream is closed"))
+	}
+	err := s.ctx.Err()
+	if err != nil {
+		return NewError(Canceled, "", fmt.Errorf("client is gone: %w", err))
+	}
+	if !s.started {
+		s.started = true
+		wh := s.w.Header()
+		wh.Set("Content-Type", "text/event-stream")
+		wh.Set("Cache-Control", "no-cache")
+		wh.Set("X-Accel-Buffering", "no") // disable buffering in nginx
+		wh.Del("Content-Length")
+		s.w.WriteHeader(http.StatusOK)
+	}
+	_, err = s.w.Write(data)
+	if err != nil {
+		return NewError(Canceled, "", err)
+	}
+	flusher, ok := s.w.(http.Flusher)
+	if ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+func (s *eventStreamImp) Send(event *Event) error {
+	buf := strings.Builder{}
+	if event.ID != "" {
+		buf.WriteString("id: " + sseSingleLine(event.ID) + "\n")
+	}
+	if event.Event != "" {
+		buf.WriteString("event: " + sseSingleLine(event.Event) + "\n")
+	}
+	if event.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+	var dataStr string
+	switch data := event.Data.(type) {
+	case nil:
+	case string:
+		dataStr = data
+	case []byte:
+		dataStr = string(data)
+	default:
+		dataBytes, err := json.Marshal(data)
+		if err != nil {
+			return NewError(Internal, "", err)
+		}
+		dataStr = string(dataBytes)
+	}
+	if event.Data != nil {
+		dataStr = strings.ReplaceAll(dataStr, "\r\n", "\n")
+		for _, line := range strings.Split(dataStr, "\n") {
+			buf.WriteString("data: " + line + "\n")
+		}
+	}
+	buf.WriteString("\n")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write([]byte(buf.String()))
+}
+
+func (s *eventStreamImp) SendData(data any) error {
+	return s.Send(&Event{Data: data})
+}
+
+func (s *eventStreamImp) Comment(text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write([]byte(": " + sseSingleLine(text) + "\n\n"))
+}
+
+// close: writes lastEvent if not nil and stream is started, and closes the stream
+// returns true if stream was started
+func (s *eventStreamImp) close(lastEvent []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started && lastEvent != nil {
+		_ = s.write(lastEvent)
+	}
+	s.closed = true
+	return s.started
+}
+
+// writeHeartbeat: writes a heartbeat comment if stream is started, heartbeats do not start
+// the stream, so that handler can still respond with a normal error before sending any event
+func (s *eventStreamImp) writeHeartbeat() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		return nil
+	}
+	return s.write([]byte(": heartbeat\n\n"))
+}
+
+func (s *eventStreamImp) heartbeat(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if s.writeHeartbeat() != nil {
+				return
+			}
+		}
+	}
+}
+
+func sseSingleLine(str string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(str)
+}
+
+func TranslateSSEHandler(handler SSEHandler, options ...HandlerOption) http.HandlerFunc {
+	config := newHandlerConfig(options)
+	handlerName := config.getHandlerName(handler)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w, request, finish := startRequest(w, r, config, handlerName)
+		defer finish()
+		r = request.r
+		release, err := applyLimits(w, request, config)
+		if err != nil {
+			handleError(err, w, request)
+			return
+		}
+		defer release()
+		err = request.parseForm(w)
+		if err != nil {
+			if _, isRpcErr := err.(RPCError); isRpcErr {
+				handleError(err, w, request)
+				return
+			}
+			http.Error(w, "error in parsing form", http.StatusBadRequest)
+			return
+		}
+		stream := &eventStreamImp{
+			w:           w,
+			ctx:         r.Context(),
+			lastEventID: r.Header.Get("Last-Event-ID"),
+		}
+		interval := config.getSSEHeartbeatInterval()
+		if interval > 0 {
+			done := make(chan struct{})
+			defer close(done)
+			go stream.heartbeat(interval, done)
+		}
+		err = callSSEHandler(handler, request, stream)
+		var rpcErr RPCError
+		var lastEvent []byte
+		// if client is gone, that's how SSE streams normally end
+		if err != nil && r.Context().Err() == nil {
+			var isRpcErr bool
+			rpcErr, isRpcErr = err.(RPCError)
+			if !isRpcErr {
+				rpcErr = NewError(Unknown, "", err)
+			}
+			data, _ := json.Marshal(newErrorBody(rpcErr, request.requestID))
+			lastEvent = []byte("event: error\ndata: " + string(data) + "\n\n")
+		}
+		started := stream.close(lastEvent)
+		if err == nil {
+			if !started {
+				w.WriteHeader(http.StatusNoContent)
+			}
+			return
+		}
+		if !started {
+			handleError(err, w, request)
+			return
+		}
+		if rpcErr != nil {
+			errorDispatcher(request, rpcErr)
+		}
+	}
+}
+
+func callSSEHandler(handler SSEHandler, request *requestImp, stream EventStream) (err error) {
+	defer request.releaseLocks()
+	defer recoverHandlerPanic(request, &err)
+	return handler(request, stream)
+}
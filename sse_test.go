@@ -0,0 +1,178 @@
+package ripo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilius/is/v2"
+)
+
+func TestSSE_Events(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(func(req Request, stream EventStream) error {
+		err := stream.Send(&Event{
+			ID:    "1",
+			Event: "progress",
+			Data:  map[string]int{"percent": 50},
+			Retry: 3 * time.Second,
+		})
+		if err != nil {
+			return err
+		}
+		err = stream.SendData("line1\nline2")
+		if err != nil {
+			return err
+		}
+		return stream.Comment("bye")
+	}, "GET", "/events", nil, "")
+	is.Equal(http.StatusOK, w.Code)
+	is.True(w.Flushed)
+	is.Equal("text/event-stream", w.Header().Get("Content-Type"))
+	is.Equal("no-cache", w.Header().Get("Cache-Control"))
+	is.Equal(
+		"id: 1\nevent: progress\nretry: 3000\ndata: {\"percent\":50}\n\n"+
+			"data: line1\ndata: line2\n\n"+
+			": bye\n\n",
+		w.Body.String(),
+	)
+}
+
+func TestSSE_LastEventID(t *testing.T) {
+	is := is.New(t)
+	r, err := http.NewRequest("GET", "/events?name=John", nil)
+	is.NotErr(err)
+	r.Header.Set("Last-Event-ID", "3\n")
+	w := httptest.NewRecorder()
+	TranslateSSEHandler(func(req Request, stream EventStream) error {
+		name, err := req.GetString("name", FromForm)
+		if err != nil {
+			return err
+		}
+		return stream.Send(&Event{
+			ID:   stream.LastEventID(),
+			Data: "resumed for " + *name,
+		})
+	}, SSEHeartbeatInterval(-1))(w, r)
+	is.Equal("id: 3\ndata: resumed for John\n\n", w.Body.String())
+}
+
+func TestSSE_NoEvents(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(func(req Request, stream EventStream) error {
+		return nil
+	}, "GET", "/events", nil, "")
+	is.Equal(http.StatusNoContent, w.Code)
+	is.Equal("", w.Body.String())
+}
+
+func TestSSE_ErrorBeforeStart(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(func(req Request, stream EventStream) error {
+		return NewError(PermissionDenied, "not your job", nil)
+	}, "GET", "/events", nil, "")
+	is.Equal(http.StatusForbidden, w.Code)
+	is.Equal("{\"code\":\"PermissionDenied\",\"error\":\"not your job\"}", w.Body.String())
+}
+
+func TestSSE_ErrorAfterStart(t *testing.T) {
+	is := is.New(t)
+	var dispatched RPCError
+	SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {
+		dispatched = rpcErr
+	})
+	defer SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {})
+	w := doTestRequest(func(req Request, stream EventStream) error {
+		err := stream.SendData("started")
+		if err != nil {
+			return err
+		}
+		panic("job crashed")
+	}, "GET", "/events", nil, "")
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal(
+		"data: started\n\nevent: error\ndata: {\"code\":\"Internal\",\"error\":\"Internal\"}\n\n",
+		w.Body.String(),
+	)
+	is.NotNil(dispatched)
+	is.Equal(Internal, dispatched.Code())
+}
+
+func TestSSE_Heartbeat(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(func(req Request, stream EventStream) error {
+		err := stream.SendData("started")
+		if err != nil {
+			return err
+		}
+		time.Sleep(35 * time.Millisecond)
+		return stream.SendData("done")
+	}, "GET", "/events", nil, "", SSEHeartbeatInterval(10*time.Millisecond))
+	is.Equal(http.StatusOK, w.Code)
+	body := w.Body.String()
+	is.True(strings.HasPrefix(body, "data: started\n\n: heartbeat\n\n"))
+	is.True(strings.HasSuffix(body, "data: done\n\n"))
+}
+
+func TestSSE_HeartbeatBeforeStart(t *testing.T) {
+	is := is.New(t)
+	{
+		// heartbeats do not start the stream
+		w := doTestRequest(func(req Request, stream EventStream) error {
+			time.Sleep(35 * time.Millisecond)
+			return NewError(NotFound, "no such job", nil)
+		}, "GET", "/events", nil, "", SSEHeartbeatInterval(5*time.Millisecond))
+		is.Equal(http.StatusNotFound, w.Code)
+		is.Equal("{\"code\":\"NotFound\",\"error\":\"no such job\"}", w.Body.String())
+	}
+	{
+		w := doTestRequest(func(req Request, stream EventStream) error {
+			time.Sleep(35 * time.Millisecond)
+			return stream.SendData("done")
+		}, "GET", "/events", nil, "", SSEHeartbeatInterval(5*time.Millisecond))
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("data: done\n\n", w.Body.String()[:12])
+	}
+}
+
+func TestSSE_ClientGone(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	r, err := http.NewRequestWithContext(ctx, "GET", "/events", nil)
+	is.NotErr(err)
+	w := httptest.NewRecorder()
+	dispatched := false
+	SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {
+		dispatched = true
+	})
+	defer SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {})
+	var sendErr error
+	TranslateSSEHandler(func(req Request, stream EventStream) error {
+		for i := 0; ; i++ {
+			if i == 2 {
+				cancel()
+			}
+			sendErr = stream.SendData(i)
+			if sendErr != nil {
+				<-stream.Context().Done()
+				return sendErr
+			}
+		}
+	})(w, r)
+	AssertError(t, sendErr, Canceled, "Canceled")
+	is.False(dispatched)
+	is.Equal("data: 0\n\ndata: 1\n\n", w.Body.String())
+}
+
+func TestSetSSEHeartbeatInterval(t *testing.T) {
+	is := is.New(t)
+	defer SetSSEHeartbeatInterval(sseHeartbeatInterval)
+	SetSSEHeartbeatInterval(0)
+	is.Equal(time.Duration(0), newHandlerConfig(nil).getSSEHeartbeatInterval())
+	is.ShouldPanic(func() {
+		SetSSEHeartbeatInterval(-time.Second)
+	})
+}
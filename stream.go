@@ -0,0 +1,181 @@
+package ripo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// StreamFunc: can be used as Response.Data to stream items as NDJSON (one json per line)
+// call send for each item, it returns error if client is gone or item can not be encoded
+// returning an error after sending some items terminates the stream
+type StreamFunc func(send func(item any) error) error
+
+// isStreamData: Response.Data types that are streamed instead of being encoded at once:
+// io.Reader (raw bytes), StreamFunc and receivable channels (NDJSON)
+func isStreamData(data any) bool {
+	switch data.(type) {
+	case []byte, string:
+		return false
+	case io.Reader, StreamFunc, func(send func(item any) error) error:
+		return true
+	}
+	if data == nil {
+		return false
+	}
+	t := reflect.TypeOf(data)
+	return t.Kind() == reflect.Chan && t.ChanDir()&reflect.RecvDir != 0
+}
+
+// streamWriter writes status code and headers lazily on first write
+// so that errors before the first item can still be sent as a normal error response
+type streamWriter struct {
+	w           http.ResponseWriter
+	status      int
+	contentType string
+	started     bool
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if !sw.started {
+		sw.started = true
+		wh := sw.w.Header()
+		if wh.Get("Content-Type") == "" {
+			wh.Set("Content-Type", sw.contentType)
+		}
+		wh.Del("Content-Length")
+		sw.w.WriteHeader(sw.status)
+	}
+	n, err := sw.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	flusher, ok := sw.w.(http.Flusher)
+	if ok {
+		flusher.Flush()
+	}
+	return n, nil
+}
+
+func writeStream(w http.ResponseWriter, res *Response, request *requestImp) {
+	ctx := request.Context()
+	sw := &streamWriter{
+		w:           w,
+		status:      res.statusCode(),
+		contentType: ndjsonContentType,
+	}
+	var err error
+	switch data := res.Data.(type) {
+	case io.Reader:
+		sw.contentType = "application/octet-stream"
+		if res.ContentType != "" {
+			sw.contentType = res.ContentType
+		}
+		err = streamReader(sw, data)
+		closer, ok := data.(io.Closer)
+		if ok {
+			closer.Close()
+		}
+	case StreamFunc:
+		err = streamFunc(sw, ctx.Done(), data)
+	case func(send func(item any) error) error:
+		err = streamFunc(sw, ctx.Done(), data)
+	default:
+		err = streamChannel(sw, ctx.Done(), reflect.ValueOf(data))
+	}
+	if err == nil {
+		if !sw.started {
+			// empty stream
+			_, _ = sw.Write(nil)
+		}
+		return
+	}
+	if ctx.Err() != nil {
+		// client is gone, nothing to report to client
+		err = NewError(Canceled, "", fmt.Errorf("stream canceled: %w", ctx.Err()))
+	}
+	if !sw.started {
+		handleError(err, w, request)
+		return
+	}
+	rpcErr, isRpcErr := err.(RPCError)
+	if !isRpcErr {
+		rpcErr = NewError(Unknown, "", err)
+	}
+	if sw.contentType == ndjsonContentType && ctx.Err() == nil {
+		// last line reports the error, so client knows the stream is incomplete
+		line, _ := json.Marshal(newErrorBody(rpcErr, request.requestID))
+		_, _ = sw.Write(append(line, '\n'))
+	}
+	errorDispatcher(request, rpcErr)
+}
+
+func streamReader(sw *streamWriter, reader io.Reader) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			_, writeErr := sw.Write(buf[:n])
+			if writeErr != nil {
+				return writeErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func writeNDJSONItem(sw *streamWriter, item any) error {
+	line, err := json.Marshal(item)
+	if err != nil {
+		return NewError(Internal, "", err)
+	}
+	_, err = sw.Write(append(line, '\n'))
+	return err
+}
+
+func streamFunc(sw *streamWriter, done <-chan struct{}, stream func(send func(item any) error) error) error {
+	return stream(func(item any) error {
+		select {
+		case <-done:
+			return NewError(Canceled, "", fmt.Errorf("client is gone"))
+		default:
+		}
+		return writeNDJSONItem(sw, item)
+	})
+}
+
+// streamChannel: writes items until channel is closed
+// an item of type error terminates the stream with that error
+func streamChannel(sw *streamWriter, done <-chan struct{}, ch reflect.Value) error {
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)},
+		{Dir: reflect.SelectRecv, Chan: ch},
+	}
+	for {
+		chosen, value, ok := reflect.Select(cases)
+		if chosen == 0 {
+			return NewError(Canceled, "", fmt.Errorf("client is gone"))
+		}
+		if !ok {
+			return nil
+		}
+		item := value.Interface()
+		itemErr, isErr := item.(error)
+		if isErr {
+			return itemErr
+		}
+		err := writeNDJSONItem(sw, item)
+		if err != nil {
+			return err
+		}
+	}
+}
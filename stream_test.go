@@ -0,0 +1,207 @@
+package ripo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+func TestStream_Reader(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(func(req Request) (*Response, error) {
+		return &Response{
+			Data:        io.NopCloser(strings.NewReader("id,name\n1,John\n")),
+			ContentType: "text/csv",
+		}, nil
+	}, "GET", "", nil, "")
+	is.Equal(http.StatusOK, w.Code)
+	is.True(w.Flushed)
+	is.Equal("text/csv", w.Header().Get("Content-Type"))
+	is.Equal("id,name\n1,John\n", w.Body.String())
+}
+
+func TestStream_ReaderDefaultContentType(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(func(req Request) (*Response, error) {
+		return &Response{
+			Data:       strings.NewReader("abc"),
+			StatusCode: http.StatusCreated,
+		}, nil
+	}, "GET", "", nil, "")
+	is.Equal(http.StatusCreated, w.Code)
+	is.Equal("application/octet-stream", w.Header().Get("Content-Type"))
+	is.Equal("abc", w.Body.String())
+}
+
+type failingReader struct {
+	data []byte
+}
+
+func (fr *failingReader) Read(p []byte) (int, error) {
+	if len(fr.data) == 0 {
+		return 0, NewError(DataLoss, "disk is on fire", nil)
+	}
+	n := copy(p, fr.data)
+	fr.data = fr.data[n:]
+	return n, nil
+}
+
+func TestStream_ReaderError(t *testing.T) {
+	is := is.New(t)
+	{
+		// error before any data: normal error response
+		w := doTestRequest(func(req Request) (*Response, error) {
+			return &Response{Data: &failingReader{}}, nil
+		}, "GET", "", nil, "")
+		is.Equal(http.StatusInternalServerError, w.Code)
+		is.Equal("{\"code\":\"DataLoss\",\"error\":\"disk is on fire\"}", w.Body.String())
+	}
+	{
+		// error after some data: stream is terminated
+		var dispatched RPCError
+		SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {
+			dispatched = rpcErr
+		})
+		defer SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {})
+		w := doTestRequest(func(req Request) (*Response, error) {
+			return &Response{Data: &failingReader{data: []byte("abc")}}, nil
+		}, "GET", "", nil, "")
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("abc", w.Body.String())
+		is.NotNil(dispatched)
+		is.Equal(DataLoss, dispatched.Code())
+	}
+}
+
+func TestStream_Channel(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(func(req Request) (*Response, error) {
+		ch := make(chan map[string]int)
+		go func() {
+			defer close(ch)
+			for i := 1; i <= 3; i++ {
+				ch <- map[string]int{"n": i}
+			}
+		}()
+		return &Response{Data: (<-chan map[string]int)(ch)}, nil
+	}, "GET", "", nil, "")
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal("application/x-ndjson", w.Header().Get("Content-Type"))
+	is.Equal("{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n", w.Body.String())
+}
+
+func TestStream_ChannelError(t *testing.T) {
+	is := is.New(t)
+	var dispatched RPCError
+	SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {
+		dispatched = rpcErr
+	})
+	defer SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {})
+	w := doTestRequest(func(req Request) (*Response, error) {
+		ch := make(chan any, 3)
+		ch <- 1
+		ch <- fmt.Errorf("db connection lost")
+		ch <- 2
+		close(ch)
+		return &Response{Data: ch}, nil
+	}, "GET", "", nil, "")
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal("1\n{\"code\":\"Unknown\",\"error\":\"Unknown\"}\n", w.Body.String())
+	is.NotNil(dispatched)
+	is.Equal("db connection lost", dispatched.Cause().Error())
+}
+
+func TestStream_Func(t *testing.T) {
+	is := is.New(t)
+	w := doTestRequest(func(req Request) (*Response, error) {
+		return &Response{
+			Data: StreamFunc(func(send func(item any) error) error {
+				for i := 0; i < 2; i++ {
+					err := send([]int{i})
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			}),
+		}, nil
+	}, "GET", "", nil, "")
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal("application/x-ndjson", w.Header().Get("Content-Type"))
+	is.Equal("[0]\n[1]\n", w.Body.String())
+}
+
+func TestStream_FuncErrors(t *testing.T) {
+	is := is.New(t)
+	{
+		w := doTestRequest(func(req Request) (*Response, error) {
+			return &Response{
+				Data: func(send func(item any) error) error {
+					return NewError(NotFound, "no such export", nil)
+				},
+			}, nil
+		}, "GET", "", nil, "")
+		is.Equal(http.StatusNotFound, w.Code)
+		is.Equal("{\"code\":\"NotFound\",\"error\":\"no such export\"}", w.Body.String())
+	}
+	{
+		w := doTestRequest(func(req Request) (*Response, error) {
+			return &Response{
+				Data: StreamFunc(func(send func(item any) error) error {
+					err := send("a")
+					if err != nil {
+						return err
+					}
+					return NewError(Aborted, "export aborted", nil)
+				}),
+			}, nil
+		}, "GET", "", nil, "")
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("\"a\"\n{\"code\":\"Aborted\",\"error\":\"export aborted\"}\n", w.Body.String())
+	}
+	{
+		w := doTestRequest(func(req Request) (*Response, error) {
+			return &Response{
+				Data: StreamFunc(func(send func(item any) error) error {
+					return nil
+				}),
+			}, nil
+		}, "GET", "", nil, "")
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal("application/x-ndjson", w.Header().Get("Content-Type"))
+		is.Equal("", w.Body.String())
+	}
+}
+
+func TestStream_ClientGone(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	r, err := http.NewRequestWithContext(ctx, "GET", "", nil)
+	is.NotErr(err)
+	w := httptest.NewRecorder()
+	sent := 0
+	TranslateHandler(func(req Request) (*Response, error) {
+		return &Response{
+			Data: StreamFunc(func(send func(item any) error) error {
+				for {
+					err := send(sent)
+					if err != nil {
+						return err
+					}
+					sent++
+					if sent == 2 {
+						cancel()
+					}
+				}
+			}),
+		}, nil
+	})(w, r)
+	is.Equal(2, sent)
+	is.Equal("0\n1\n", w.Body.String())
+}
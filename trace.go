@@ -0,0 +1,243 @@
+package ripo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// W3C trace context headers
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// SpanContext: identity of a span, propagated in traceparent and tracestate headers
+type SpanContext struct {
+	TraceID    string // 32 lowercase hex digits
+	SpanID     string // 16 lowercase hex digits
+	Sampled    bool
+	TraceState string
+}
+
+// Traceparent: returns value of traceparent header
+func (sc *SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID + "-" + sc.SpanID + "-" + flags
+}
+
+func isLowerHex(value string) bool {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// isValidTraceHex: trace-id and parent-id must not be all zeros
+func isValidTraceHex(value string, length int) bool {
+	return len(value) == length && isLowerHex(value) && strings.Trim(value, "0") != ""
+}
+
+// ParseTraceparent: parses value of traceparent header
+func ParseTraceparent(value string) (*SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return nil, fmt.Errorf("invalid traceparent %#v", value)
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if len(version) != 2 || version == "ff" || !isLowerHex(version) {
+		return nil, fmt.Errorf("invalid traceparent version %#v", version)
+	}
+	if version == "00" && len(parts) != 4 {
+		return nil, fmt.Errorf("invalid traceparent %#v", value)
+	}
+	if !isValidTraceHex(traceID, 32) {
+		return nil, fmt.Errorf("invalid trace-id %#v", traceID)
+	}
+	if !isValidTraceHex(spanID, 16) {
+		return nil, fmt.Errorf("invalid parent-id %#v", spanID)
+	}
+	if len(flags) != 2 || !isLowerHex(flags) {
+		return nil, fmt.Errorf("invalid trace-flags %#v", flags)
+	}
+	flagsByte, _ := hex.DecodeString(flags)
+	return &SpanContext{
+		TraceID: traceID,
+		SpanID:  spanID,
+		Sampled: flagsByte[0]&1 == 1,
+	}, nil
+}
+
+func randomHex(size int) string {
+	id := make([]byte, size)
+	_, err := rand.Read(id)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
+
+// Span: a handler execution, exported after response is written
+type Span struct {
+	SpanContext
+	ParentSpanID string // empty for root span
+	Name         string
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]any
+}
+
+// SpanExporter: receives finished and sampled spans, must be safe for concurrent use
+type SpanExporter interface {
+	ExportSpan(span *Span)
+}
+
+// InMemoryExporter: keeps exported spans in memory, useful in tests
+type InMemoryExporter struct {
+	mutex sync.Mutex
+	spans []*Span
+}
+
+func (e *InMemoryExporter) ExportSpan(span *Span) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans: returns exported spans, in the order they are finished
+func (e *InMemoryExporter) Spans() []*Span {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]*Span{}, e.spans...)
+}
+
+func (e *InMemoryExporter) Reset() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spans = nil
+}
+
+// spanExporter: global span exporter, nil means tracing is disabled
+var spanExporter SpanExporter
+
+// SetSpanExporter: set global span exporter, nil disables tracing
+// Can be overridden per handler with Tracing and DisableTracing options
+func SetSpanExporter(exporter SpanExporter) {
+	spanExporter = exporter
+}
+
+// Tracing: export spans of this handler to exporter, overriding global SetSpanExporter
+func Tracing(exporter SpanExporter) HandlerOption {
+	return func(config *handlerConfig) {
+		config.spanExporter = exporter
+	}
+}
+
+// DisableTracing: never open spans for this handler
+func DisableTracing() HandlerOption {
+	return func(config *handlerConfig) {
+		config.disableTracing = true
+	}
+}
+
+func (config *handlerConfig) getSpanExporter() SpanExporter {
+	if config.disableTracing {
+		return nil
+	}
+	if config.spanExporter == nil {
+		return spanExporter
+	}
+	return config.spanExporter
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext: returns a context with span context, that is used as parent
+// by handlers and client package for outbound calls
+func ContextWithSpanContext(ctx context.Context, sc *SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext: returns span context of current span, or nil
+func SpanContextFromContext(ctx context.Context) *SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(*SpanContext)
+	return sc
+}
+
+// InjectTraceContext: sets traceparent and tracestate headers of span context of ctx
+func InjectTraceContext(ctx context.Context, header http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if sc == nil {
+		return
+	}
+	header.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		header.Set(TracestateHeader, sc.TraceState)
+	} else {
+		header.Del(TracestateHeader)
+	}
+}
+
+// finishSpan: sets attributes of span and exports it, if it's sampled
+func finishSpan(exporter SpanExporter, span *Span, entry *AccessLogEntry) {
+	span.End = time.Now()
+	span.Attributes["handler"] = entry.Handler
+	if entry.Route != "" {
+		span.Attributes["route"] = entry.Route
+	}
+	span.Attributes["http.method"] = entry.Method
+	span.Attributes["http.path"] = entry.Path
+	span.Attributes["http.status_code"] = entry.Status
+	code := entry.Code
+	if code == "" {
+		code = "OK"
+	}
+	span.Attributes["code"] = code
+	if entry.RequestID != "" {
+		span.Attributes["requestId"] = entry.RequestID
+	}
+	if span.Sampled {
+		exporter.ExportSpan(span)
+	}
+}
+
+// startSpan: opens a span that is a child of span context of r (or its traceparent header)
+// and returns request with context of new span
+func startSpan(r *http.Request, name string) (*http.Request, *Span) {
+	span := &Span{
+		Name:       name,
+		Start:      time.Now(),
+		Attributes: map[string]any{},
+	}
+	parent := SpanContextFromContext(r.Context())
+	if parent == nil {
+		var err error
+		parent, err = ParseTraceparent(r.Header.Get(TraceparentHeader))
+		if err == nil {
+			parent.TraceState = r.Header.Get(TracestateHeader)
+		}
+	}
+	if parent != nil {
+		span.TraceID = parent.TraceID
+		span.ParentSpanID = parent.SpanID
+		span.Sampled = parent.Sampled
+		span.TraceState = parent.TraceState
+	} else {
+		span.TraceID = randomHex(16)
+		span.Sampled = true
+	}
+	span.SpanID = randomHex(8)
+	sc := span.SpanContext
+	return r.WithContext(ContextWithSpanContext(r.Context(), &sc)), span
+}
@@ -0,0 +1,139 @@
+package ripo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	is := is.New(t)
+	{
+		sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		is.NotErr(err)
+		is.Equal("4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID)
+		is.Equal("00f067aa0ba902b7", sc.SpanID)
+		is.True(sc.Sampled)
+		is.Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.Traceparent())
+	}
+	{
+		sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+		is.NotErr(err)
+		is.False(sc.Sampled)
+	}
+	{
+		// future version may have more fields
+		sc, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03-xyz")
+		is.NotErr(err)
+		is.True(sc.Sampled)
+	}
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-xyz",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+	} {
+		_, err := ParseTraceparent(value)
+		is.Msg("value=%#v", value).Err(err)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	is := is.New(t)
+	exporter := &InMemoryExporter{}
+	var dispatchedErr RPCError
+	SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {
+		dispatchedErr = rpcErr
+	})
+	defer SetErrorDispatcher(func(request ExtendedRequest, rpcErr RPCError) {})
+	var handlerSC *SpanContext
+	router := NewRouter(Tracing(exporter))
+	router.Handle("GET", "/users/{id}", func(req Request) (*Response, error) {
+		handlerSC = SpanContextFromContext(req.Context())
+		return nil, NewError(NotFound, "user not found", nil)
+	})
+	r := httptest.NewRequest("GET", "/users/12", nil)
+	r.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.Header.Set(TracestateHeader, "foo=bar")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	is.Equal(http.StatusNotFound, w.Code)
+	spans := exporter.Spans()
+	is.Equal(1, len(spans))
+	span := spans[0]
+	is.Equal("/users/{id}", span.Name)
+	is.Equal("4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID)
+	is.Equal("00f067aa0ba902b7", span.ParentSpanID)
+	is.Equal(16, len(span.SpanID))
+	is.True(span.SpanID != span.ParentSpanID)
+	is.Equal("foo=bar", span.TraceState)
+	is.True(!span.End.Before(span.Start))
+	is.Equal("/users/{id}", span.Attributes["route"])
+	is.Equal("GET", span.Attributes["http.method"])
+	is.Equal(http.StatusNotFound, span.Attributes["http.status_code"])
+	is.Equal("NotFound", span.Attributes["code"])
+	is.True(strings.HasSuffix(span.Attributes["handler"].(string), "TestTracing.func3"))
+	is.Equal(span.SpanContext, *handlerSC)
+	is.Equal("4bf92f3577b34da6a3ce929d0e0e4736", dispatchedErr.Details()["traceId"])
+}
+
+func TestTracing_Root(t *testing.T) {
+	is := is.New(t)
+	exporter := &InMemoryExporter{}
+	SetSpanExporter(exporter)
+	defer SetSpanExporter(nil)
+	handlerFunc := TranslateHandler(func(req Request) (*Response, error) {
+		return NoContent(), nil
+	})
+	{
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set(TraceparentHeader, "invalid")
+		handlerFunc(httptest.NewRecorder(), r)
+		spans := exporter.Spans()
+		is.Equal(1, len(spans))
+		is.Equal(32, len(spans[0].TraceID))
+		is.Equal("", spans[0].ParentSpanID)
+		is.True(spans[0].Sampled)
+		is.Equal("OK", spans[0].Attributes["code"])
+		is.Equal(http.StatusNoContent, spans[0].Attributes["http.status_code"])
+	}
+	exporter.Reset()
+	{
+		// not sampled
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+		handlerFunc(httptest.NewRecorder(), r)
+		is.Equal(0, len(exporter.Spans()))
+	}
+	{
+		TranslateHandler(func(req Request) (*Response, error) {
+			return NoContent(), nil
+		}, DisableTracing())(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		is.Equal(0, len(exporter.Spans()))
+	}
+}
+
+func TestInjectTraceContext(t *testing.T) {
+	is := is.New(t)
+	header := http.Header{}
+	InjectTraceContext(context.Background(), header)
+	is.Equal(0, len(header))
+	ctx := ContextWithSpanContext(context.Background(), &SpanContext{
+		TraceID:    "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:     "00f067aa0ba902b7",
+		Sampled:    true,
+		TraceState: "foo=bar",
+	})
+	InjectTraceContext(ctx, header)
+	is.Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", header.Get(TraceparentHeader))
+	is.Equal("foo=bar", header.Get(TracestateHeader))
+}
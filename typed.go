@@ -0,0 +1,374 @@
+package ripo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+type requestContextKey struct{}
+
+// RequestFromContext: returns the Request of a typed handler from its context, or nil
+func RequestFromContext(ctx context.Context) Request {
+	req, _ := ctx.Value(requestContextKey{}).(Request)
+	return req
+}
+
+// TypedInfo: input and output types and parameters of a handler created by Typed
+type TypedInfo struct {
+	funcName string
+	inType   reflect.Type
+	outType  reflect.Type
+	params   []*Param
+	hasBody  bool
+}
+
+// FuncName: full name of typed function, which is used as name of handler
+func (info *TypedInfo) FuncName() string {
+	return info.funcName
+}
+
+// InputType: type of In
+func (info *TypedInfo) InputType() reflect.Type {
+	return info.inType
+}
+
+// OutputType: type of Out
+func (info *TypedInfo) OutputType() reflect.Type {
+	return info.outType
+}
+
+// Params: parameters bound from path, query and header
+func (info *TypedInfo) Params() []*Param {
+	return append([]*Param{}, info.params...)
+}
+
+// HasBody: reports whether In has any field that is bound from request body
+func (info *TypedInfo) HasBody() bool {
+	return info.hasBody
+}
+
+// typedInfos: info of handlers created by Typed, keyed by handlerKey
+var typedInfos sync.Map
+
+// handlerKey: identity of handler function value, which is the pointer to its closure
+// handlers created by separate calls of Typed share the same code, but not the same closure
+func handlerKey(handler Handler) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&handler))
+}
+
+// TypedInfoOf: returns info of handler created by Typed, or nil if it's not a typed handler
+func TypedInfoOf(handler Handler) *TypedInfo {
+	if handler == nil {
+		return nil
+	}
+	info, ok := typedInfos.Load(handlerKey(handler))
+	if !ok {
+		return nil
+	}
+	return info.(*TypedInfo)
+}
+
+// handlerFunctionName: name of handler function, or name of typed function for typed handlers
+func handlerFunctionName(handler Handler) string {
+	info := TypedInfoOf(handler)
+	if info != nil {
+		return info.funcName
+	}
+	return getFunctionName(handler)
+}
+
+type typedField struct {
+	index     []int
+	name      string
+	in        string
+	required  bool
+	fieldType reflect.Type
+}
+
+var typedParamTags = []string{ParamInPath, ParamInQuery, ParamInHeader}
+
+// Typed: creates a handler from a function with typed input and output
+// In must be a struct (or pointer to struct), which is decoded from request body (with json tags),
+// and its fields with tags like `path:"id"`, `query:"limit"` or `header:"X-Token"` are bound from
+// path parameters (of Router), query and headers, and `description:"..."` tag is used for docs
+// path parameters are required, and query and header parameters are required with `,required` option
+// like `query:"limit,required"`, missing required parameter results in MissingArgument error
+// supported types for these fields: string, bool, int and uint types, float types, time.Time (RFC3339),
+// []string (comma-separated), and pointer to these types
+// Out is used as Data of response, unless it's a *Response, which is returned as is
+// Request is accessible with RequestFromContext(ctx)
+// returned Handler is named after fn (in logs, metrics and tracing), and Router.Handle documents
+// its parameters and types in OpenAPI, which are also available with TypedInfoOf
+func Typed[In any, Out any](fn func(ctx context.Context, in In) (Out, error)) Handler {
+	inType := reflect.TypeOf((*In)(nil)).Elem()
+	outType := reflect.TypeOf((*Out)(nil)).Elem()
+	structType := inType
+	inIsPtr := inType.Kind() == reflect.Ptr
+	if inIsPtr {
+		structType = inType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("Typed: input type %v is not a struct", inType))
+	}
+	fields, hasBody := typedFields(structType, nil)
+	info := &TypedInfo{
+		funcName: getFunctionName(fn),
+		inType:   inType,
+		outType:  outType,
+		hasBody:  hasBody,
+	}
+	for _, field := range fields {
+		info.params = append(info.params, &Param{
+			Name:        field.name,
+			In:          field.in,
+			Type:        field.fieldType,
+			Required:    field.required,
+			Description: structType.FieldByIndex(field.index).Tag.Get("description"),
+		})
+	}
+	handler := func(req Request) (*Response, error) {
+		inValue := reflect.New(structType)
+		err := bindTyped(req, inValue, fields, hasBody)
+		if err != nil {
+			return nil, err
+		}
+		var in In
+		if inIsPtr {
+			in = inValue.Interface().(In)
+		} else {
+			in = inValue.Elem().Interface().(In)
+		}
+		ctx := context.WithValue(req.Context(), requestContextKey{}, req)
+		out, err := fn(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		res, isResponse := any(out).(*Response)
+		if isResponse {
+			return res, nil
+		}
+		return &Response{Data: out}, nil
+	}
+	typedInfos.Store(handlerKey(handler), info)
+	return handler
+}
+
+func typedParamTag(field reflect.StructField) (string, string, bool) {
+	for _, in := range typedParamTags {
+		tag, ok := field.Tag.Lookup(in)
+		if ok {
+			return in, tag, true
+		}
+	}
+	return "", "", false
+}
+
+// typedFields: returns fields bound from path, query and header, and whether any field is bound from body
+func typedFields(structType reflect.Type, parentIndex []int) ([]*typedField, bool) {
+	fields := []*typedField{}
+	hasBody := false
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		index := append(append([]int{}, parentIndex...), i)
+		in, tag, isParam := typedParamTag(field)
+		if !isParam {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+				subFields, subHasBody := typedFields(field.Type, index)
+				fields = append(fields, subFields...)
+				hasBody = hasBody || subHasBody
+				continue
+			}
+			if field.IsExported() && field.Tag.Get("json") != "-" {
+				hasBody = true
+			}
+			continue
+		}
+		if !field.IsExported() {
+			panic(fmt.Sprintf("Typed: field %v with %v tag is not exported", field.Name, in))
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		if !isTypedParamType(field.Type) {
+			panic(fmt.Sprintf("Typed: unsupported type %v for %v parameter %#v", field.Type, in, name))
+		}
+		fields = append(fields, &typedField{
+			index:     index,
+			name:      name,
+			in:        in,
+			required:  in == ParamInPath || options == "required",
+			fieldType: field.Type,
+		})
+	}
+	return fields, hasBody
+}
+
+func isTypedParamType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	}
+	return false
+}
+
+func bindTyped(req Request, inValue reflect.Value, fields []*typedField, hasBody bool) error {
+	if hasBody {
+		body, err := req.Body()
+		if err != nil {
+			return err
+		}
+		if len(body) > 0 {
+			err = req.BodyTo(inValue.Interface())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	extReq, ok := req.(ExtendedRequest)
+	if !ok {
+		return NewError(Internal, "", fmt.Errorf("request of type %T is not an ExtendedRequest", req))
+	}
+	query := req.URL().Query()
+	sources := map[string]ExtendedRequest{
+		ParamInPath: newPathParamsRequest(extReq),
+		ParamInQuery: &valueLookupRequest{
+			ExtendedRequest: extReq,
+			lookup:          query.Get,
+		},
+		ParamInHeader: &valueLookupRequest{
+			ExtendedRequest: extReq,
+			lookup: func(key string) string {
+				return extReq.Header(http.CanonicalHeaderKey(key))
+			},
+		},
+	}
+	structValue := inValue.Elem()
+	for _, field := range fields {
+		value, err := typedParamValue(sources[field.in], field)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			if field.required {
+				return NewError(
+					MissingArgument,
+					fmt.Sprintf("missing '%v'", field.name),
+					nil,
+				).Add("in", field.in)
+			}
+			continue
+		}
+		fieldValue := structValue.FieldByIndex(field.index)
+		if fieldValue.Kind() == reflect.Ptr {
+			fieldValue.Set(reflect.New(fieldValue.Type().Elem()))
+			fieldValue = fieldValue.Elem()
+		}
+		fieldValue.Set(reflect.ValueOf(value).Convert(fieldValue.Type()))
+	}
+	return nil
+}
+
+// typedParamValue: returns nil if parameter is missing
+func typedParamValue(source ExtendedRequest, field *typedField) (any, error) {
+	t := field.fieldType
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		value, err := FromForm.GetTime(source, field.name)
+		if value == nil || err != nil {
+			return nil, err
+		}
+		return *value, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		value, err := FromForm.GetString(source, field.name)
+		if value == nil || err != nil {
+			return nil, err
+		}
+		return *value, nil
+	case reflect.Bool:
+		value, err := FromForm.GetBool(source, field.name)
+		if value == nil || err != nil {
+			return nil, err
+		}
+		return *value, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value, err := FromForm.GetInt(source, field.name)
+		if value == nil || err != nil {
+			return nil, err
+		}
+		var outOfRange bool
+		if isUintKind(t.Kind()) {
+			outOfRange = *value < 0 || reflect.Zero(t).OverflowUint(uint64(*value))
+		} else {
+			outOfRange = reflect.Zero(t).OverflowInt(int64(*value))
+		}
+		if outOfRange {
+			return nil, NewError(
+				InvalidArgument,
+				fmt.Sprintf("invalid '%v', out of range", field.name),
+				nil,
+			).Add("value", *value).Add("type", t.String())
+		}
+		return *value, nil
+	case reflect.Float32, reflect.Float64:
+		value, err := FromForm.GetFloat(source, field.name)
+		if value == nil || err != nil {
+			return nil, err
+		}
+		return *value, nil
+	case reflect.Slice:
+		value, err := FromForm.GetStringList(source, field.name)
+		if value == nil || err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+	return nil, NewError(Internal, "", fmt.Errorf("unsupported parameter type %v", t))
+}
+
+func isUintKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// documentTyped: documents parameters, body and response types of typed handler
+func (route *Route) documentTyped(info *TypedInfo) {
+	route.Params(info.params...)
+	switch route.method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+	default:
+		if info.hasBody {
+			route.bodyType = info.inType
+		}
+	}
+	if info.outType != reflect.TypeOf((*Response)(nil)) {
+		route.resultType = info.outType
+	}
+}
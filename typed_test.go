@@ -0,0 +1,218 @@
+package ripo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+type typedTestUpdateInput struct {
+	ID      int      `path:"id" description:"user id"`
+	DryRun  bool     `query:"dryRun"`
+	Fields  []string `query:"fields"`
+	Limit   *uint8   `query:"limit"`
+	Token   string   `header:"X-Token,required"`
+	Name    string   `json:"name"`
+	Age     int      `json:"age,omitempty"`
+	ignored string
+}
+
+type typedTestUser struct {
+	ID     int      `json:"id"`
+	Name   string   `json:"name"`
+	DryRun bool     `json:"dryRun"`
+	Fields []string `json:"fields"`
+	Limit  *uint8   `json:"limit"`
+	Caller string   `json:"caller"`
+}
+
+func typedTestUpdateUser(ctx context.Context, in *typedTestUpdateInput) (*typedTestUser, error) {
+	if in.Name == "" {
+		return nil, NewError(MissingArgument, "missing 'name'", nil)
+	}
+	return &typedTestUser{
+		ID:     in.ID,
+		Name:   in.Name,
+		DryRun: in.DryRun,
+		Fields: in.Fields,
+		Limit:  in.Limit,
+		Caller: RequestFromContext(ctx).HandlerName(),
+	}, nil
+}
+
+type typedTestGetInput struct {
+	ID int `path:"id"`
+}
+
+func newTypedTestRouter() *Router {
+	router := NewRouter()
+	router.Handle("PUT", "/users/{id}", Typed(typedTestUpdateUser))
+	router.Handle("GET", "/users/{id}", Typed(func(ctx context.Context, in typedTestGetInput) (*Response, error) {
+		return NoContent(), nil
+	}))
+	return router
+}
+
+// typedTestHeader: returns header of a json request, with given header added
+func typedTestHeader(header http.Header) http.Header {
+	jsonHeader := http.Header{"Content-Type": {"application/json"}}
+	for key, values := range header {
+		jsonHeader[key] = values
+	}
+	return jsonHeader
+}
+
+func TestTyped(t *testing.T) {
+	is := is.New(t)
+	router := newTypedTestRouter()
+	header := http.Header{"X-Token": []string{"abc"}}
+	{
+		w := doTestRequest(router, "PUT", "/users/12?dryRun=true&fields=a,b&limit=5", typedTestHeader(header), `{"name":"John"}`)
+		is.Equal(http.StatusOK, w.Code)
+		user := &typedTestUser{}
+		is.NotErr(json.Unmarshal(w.Body.Bytes(), user))
+		is.Equal(12, user.ID)
+		is.Equal("John", user.Name)
+		is.True(user.DryRun)
+		is.Equal([]string{"a", "b"}, user.Fields)
+		is.Equal(uint8(5), *user.Limit)
+		is.Equal("github.com/ilius/ripo.typedTestUpdateUser", user.Caller)
+	}
+	{
+		w := doTestRequest(router, "GET", "/users/12", typedTestHeader(nil), "")
+		is.Equal(http.StatusNoContent, w.Code)
+	}
+}
+
+func TestTyped_Errors(t *testing.T) {
+	is := is.New(t)
+	router := newTypedTestRouter()
+	header := http.Header{"X-Token": []string{"abc"}}
+	for _, tc := range []struct {
+		path   string
+		body   string
+		header http.Header
+		status int
+		body2  string
+	}{
+		{"/users/12", `{"name":"John"}`, nil, http.StatusBadRequest, `{"code":"MissingArgument","error":"missing 'X-Token'"}`},
+		{"/users/x", `{"name":"John"}`, header, http.StatusBadRequest, `{"code":"InvalidArgument","error":"invalid 'id', must be integer"}`},
+		{"/users/12?limit=300", `{"name":"John"}`, header, http.StatusBadRequest, `{"code":"InvalidArgument","error":"invalid 'limit', out of range"}`},
+		{"/users/12?limit=-1", `{"name":"John"}`, header, http.StatusBadRequest, `{"code":"InvalidArgument","error":"invalid 'limit', out of range"}`},
+		{"/users/12?dryRun=yes", `{"name":"John"}`, header, http.StatusBadRequest, `{"code":"InvalidArgument","error":"invalid 'dryRun', must be true or false"}`},
+		{"/users/12", `{"name":`, header, http.StatusBadRequest, `{"code":"InvalidArgument","error":"request body is not a valid json"}`},
+		{"/users/12", ``, header, http.StatusBadRequest, `{"code":"MissingArgument","error":"missing 'name'"}`},
+	} {
+		w := doTestRequest(router, "PUT", tc.path, typedTestHeader(tc.header), tc.body)
+		is.Msg("path=%v", tc.path).Equal(tc.status, w.Code)
+		is.Msg("path=%v", tc.path).Equal(tc.body2, w.Body.String())
+	}
+}
+
+func TestTyped_Metadata(t *testing.T) {
+	is := is.New(t)
+	info := TypedInfoOf(Typed(typedTestUpdateUser))
+	is.Equal("github.com/ilius/ripo.typedTestUpdateUser", info.FuncName())
+	is.Equal(reflect.TypeOf(&typedTestUpdateInput{}), info.InputType())
+	is.Equal(reflect.TypeOf(&typedTestUser{}), info.OutputType())
+	is.True(info.HasBody())
+	params := info.Params()
+	is.Equal(5, len(params))
+	is.Equal(&Param{Name: "id", In: ParamInPath, Type: reflect.TypeOf(0), Required: true, Description: "user id"}, params[0])
+	is.Equal(&Param{Name: "X-Token", In: ParamInHeader, Type: reflect.TypeOf(""), Required: true}, params[4])
+	is.False(TypedInfoOf(Typed(func(ctx context.Context, in typedTestGetInput) (int, error) { return 0, nil })).HasBody())
+	// not a typed handler
+	is.Nil(TypedInfoOf(bodyLengthHandler))
+	is.Nil(TypedInfoOf(nil))
+
+	doc := newTypedTestRouter().OpenAPI(&OpenAPIInfo{Title: "Test", Version: "1"})
+	put := doc["paths"].(map[string]any)["/users/{id}"].(map[string]any)["put"].(map[string]any)
+	is.Equal("typedTestUpdateUser", put["operationId"])
+	is.Equal(5, len(put["parameters"].([]any)))
+	is.Equal(
+		`{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/github.com_ilius_ripo.typedTestUpdateInput"}}},"required":true}`,
+		jsonOf(put["requestBody"]),
+	)
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+	is.Equal(
+		`{"properties":{"age":{"format":"int64","type":"integer"},"name":{"type":"string"}},"required":["name"],"type":"object"}`,
+		jsonOf(schemas["github.com_ilius_ripo.typedTestUpdateInput"]),
+	)
+	get := doc["paths"].(map[string]any)["/users/{id}"].(map[string]any)["get"].(map[string]any)
+	_, hasBody := get["requestBody"]
+	is.False(hasBody)
+	is.Equal(`{"description":"OK"}`, jsonOf(get["responses"].(map[string]any)["200"]))
+}
+
+func TestTyped_Panics(t *testing.T) {
+	is := is.New(t)
+	is.ShouldPanic(func() {
+		Typed(func(ctx context.Context, in int) (int, error) { return 0, nil })
+	})
+	is.ShouldPanic(func() {
+		Typed(func(ctx context.Context, in struct {
+			Data map[string]string `query:"data"`
+		}) (int, error) {
+			return 0, nil
+		})
+	})
+}
+
+func TestTyped_TranslateHandler(t *testing.T) {
+	is := is.New(t)
+	handler := Typed(func(ctx context.Context, in struct {
+		Name string `query:"name,required"`
+	}) (map[string]string, error) {
+		return map[string]string{"greeting": "Hello " + in.Name}, nil
+	})
+	w := doTestRequest(handler, "GET", "/hello?name=John", nil, "")
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal(`{"greeting":"Hello John"}`, w.Body.String())
+}
+
+func typedTestHandlerName(ctx context.Context, in struct{}) (string, error) {
+	return RequestFromContext(ctx).HandlerName(), nil
+}
+
+func typedTestHandlerName2(ctx context.Context, in struct{}) (string, error) {
+	return RequestFromContext(ctx).HandlerName(), nil
+}
+
+func TestTyped_HandlerName(t *testing.T) {
+	is := is.New(t)
+	router := NewRouter()
+	router.Handle("GET", "/a", Typed(typedTestHandlerName))
+	router.Handle("GET", "/b", Typed(typedTestHandlerName2))
+	router.Handle("GET", "/c", Typed(typedTestHandlerName2), HandlerName("custom"))
+	for path, name := range map[string]string{
+		"/a": "github.com/ilius/ripo.typedTestHandlerName",
+		"/b": "github.com/ilius/ripo.typedTestHandlerName2",
+		"/c": "custom",
+	} {
+		w := doTestRequest(router, "GET", path, nil, "")
+		is.Equal(http.StatusOK, w.Code)
+		is.Equal(name, w.Body.String())
+	}
+	{
+		w := doTestRequest(Typed(typedTestHandlerName), "GET", "/", nil, "")
+		is.Equal("github.com/ilius/ripo.typedTestHandlerName", w.Body.String())
+	}
+	{
+		w := doTestRequest(JSONRPCHandler(map[string]Handler{
+			"a": Typed(typedTestHandlerName),
+			"b": Typed(typedTestHandlerName2),
+		}), "POST", "/", typedTestHeader(nil), `{"jsonrpc":"2.0","method":"a","id":1}`)
+		is.Equal(`{"jsonrpc":"2.0","result":"github.com/ilius/ripo.typedTestHandlerName","id":1}`, w.Body.String())
+	}
+	{
+		w := doTestRequest(ConnectHandler(map[string]Handler{
+			"/a": Typed(typedTestHandlerName),
+			"/b": Typed(typedTestHandlerName2),
+		}), "POST", "/b", typedTestHeader(nil), `{}`)
+		is.Equal(`"github.com/ilius/ripo.typedTestHandlerName2"`, w.Body.String())
+	}
+}